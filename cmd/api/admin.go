@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/validation"
+	"net/http"
+)
+
+func (app *Application) listPermissionsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	permissions, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"permissions": permissions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) listRolesHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	roles, err := app.models.Roles.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"roles": roles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) createRoleHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var input struct {
+		Role string `json:"role"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	role := &data.Role{
+		Role:        input.Role,
+		Permissions: data.Permissions{},
+	}
+
+	v := validation.New()
+
+	if data.ValidateRole(v, role); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Roles.AddRole(role)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateRole):
+			v.AddError("role", "role with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/admin/roles/%d/permissions", role.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"role": role}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteRoleHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Roles.DeleteRole(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrBuiltinRole):
+			app.builtinRoleResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "role deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) showRolePermissionsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	role, err := app.models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"role": role}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) grantRolePermissionsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.changeRolePermissions(w, r, params, app.models.Roles.AddPermissionsToRole)
+}
+
+func (app *Application) revokeRolePermissionsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.changeRolePermissions(w, r, params, app.models.Roles.RemovePermissionsFromRole)
+}
+
+// changeRolePermissions читает список прав из тела запроса, применяет к роли
+// переданное изменение и возвращает обновлённую роль
+func (app *Application) changeRolePermissions(w http.ResponseWriter, r *http.Request, params httprouter.Params,
+	change func(roleId int64, permissions ...string) error) {
+
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	permissions, ok := app.readPermissionNames(w, r)
+	if !ok {
+		return
+	}
+
+	_, err = app.models.Roles.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = change(id, permissions...)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrBuiltinRole):
+			app.builtinRoleResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	role, err := app.models.Roles.Get(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"role": role}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) listUserRolesHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, ok := app.readUserID(w, r, params)
+	if !ok {
+		return
+	}
+
+	app.writeUserRoles(w, r, id)
+}
+
+func (app *Application) grantUserRolesHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.changeUserRoles(w, r, params, app.models.Roles.AddUserRoles)
+}
+
+func (app *Application) revokeUserRolesHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.changeUserRoles(w, r, params, app.models.Roles.RemoveUserRoles)
+}
+
+func (app *Application) changeUserRoles(w http.ResponseWriter, r *http.Request, params httprouter.Params,
+	change func(userId int64, roles ...string) error) {
+
+	id, ok := app.readUserID(w, r, params)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Roles []string `json:"roles"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	known, err := app.models.Roles.Names()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validation.New()
+
+	if data.ValidateRoleNames(v, input.Roles, known); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = change(id, input.Roles...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeUserRoles(w, r, id)
+}
+
+func (app *Application) writeUserRoles(w http.ResponseWriter, r *http.Request, userId int64) {
+	roles, err := app.models.Roles.GetUserRoles(userId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user_id": userId, "roles": roles}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) listUserPermissionsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, ok := app.readUserID(w, r, params)
+	if !ok {
+		return
+	}
+
+	app.writeUserPermissions(w, r, id)
+}
+
+func (app *Application) grantUserPermissionsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.changeUserPermissions(w, r, params, app.models.Permissions.Grant)
+}
+
+func (app *Application) revokeUserPermissionsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.changeUserPermissions(w, r, params, app.models.Permissions.Revoke)
+}
+
+func (app *Application) changeUserPermissions(w http.ResponseWriter, r *http.Request, params httprouter.Params,
+	change func(userId int64, permissions ...string) error) {
+
+	id, ok := app.readUserID(w, r, params)
+	if !ok {
+		return
+	}
+
+	permissions, ok := app.readPermissionNames(w, r)
+	if !ok {
+		return
+	}
+
+	err := change(id, permissions...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeUserPermissions(w, r, id)
+}
+
+// writeUserPermissions отдаёт как права, выданные пользователю напрямую,
+// так и итоговый набор прав с учётом его ролей
+func (app *Application) writeUserPermissions(w http.ResponseWriter, r *http.Request, userId int64) {
+	granted, err := app.models.Permissions.GetGrantedForUser(userId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	effective, err := app.models.Permissions.GetForUser(userId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{
+		"user_id":     userId,
+		"permissions": granted,
+		"effective":   effective,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readUserID читает id пользователя из пути и проверяет, что такой пользователь существует.
+// Если это не так, ответ уже отправлен и возвращается false
+func (app *Application) readUserID(w http.ResponseWriter, r *http.Request, params httprouter.Params) (int64, bool) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return 0, false
+	}
+
+	exists, err := app.models.Users.Exists(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return 0, false
+	}
+
+	if !exists {
+		app.notFoundResponse(w, r)
+		return 0, false
+	}
+
+	return id, true
+}
+
+// readPermissionNames читает и проверяет список прав из тела запроса.
+// Если список некорректен, ответ уже отправлен и возвращается false
+func (app *Application) readPermissionNames(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var input struct {
+		Permissions []string `json:"permissions"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	known, err := app.models.Permissions.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	v := validation.New()
+
+	if data.ValidatePermissionNames(v, input.Permissions, known); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return nil, false
+	}
+
+	return input.Permissions, true
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"library/internal/data"
+	"library/internal/storage"
+	"library/internal/validation"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+)
+
+// multipartOverhead запас тела запроса на заголовки частей и другие поля формы
+const multipartOverhead = 1 << 20
+
+// sniffLen количество первых байт файла, по которым определяется тип содержимого
+const sniffLen = 512
+
+// orphanedBatch количество файлов удалённых событий, удаляемых за один запрос
+const orphanedBatch = 100
+
+func (app *Application) listAttachmentsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return
+	}
+
+	attachments, err := app.models.Attachments.GetAll(event.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"attachments": attachments}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// uploadAttachmentHandler принимает файл из поля file формы multipart/form-data.
+// Файл записывается в хранилище по мере чтения, не превышая config.Attachments.MaxSize,
+// а его тип определяется по содержимому, а не по заголовку клиента
+func (app *Application) uploadAttachmentHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return
+	}
+
+	maxSize := app.config.Attachments.MaxSize
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+multipartOverhead)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validation.New()
+
+	part, err := nextFilePart(mr, "file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.Is(err, io.EOF):
+			v.AddError("file", "must be provided")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &maxBytesError):
+			app.fileTooLargeResponse(w, r, maxSize)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer part.Close()
+
+	a := &data.Attachment{
+		EventId:    event.ID,
+		Filename:   part.FileName(),
+		StorageKey: data.NewAttachmentKey(event.ID),
+	}
+
+	if data.ValidateAttachment(v, a); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	sniff := make([]byte, sniffLen)
+
+	n, err := io.ReadFull(part, sniff)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	a.ContentType = http.DetectContentType(sniff[:n])
+
+	// Лишний байт сверх maxSize позволяет отличить слишком большой файл от файла ровно maxSize
+	content := io.MultiReader(bytes.NewReader(sniff[:n]), io.LimitReader(part, maxSize+1-int64(n)))
+
+	a.Size, err = app.storage.Put(r.Context(), a.StorageKey, content)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.fileTooLargeResponse(w, r, maxSize)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if a.Size == 0 || a.Size > maxSize {
+		app.deleteStoredFile(r.Context(), a.StorageKey)
+
+		if a.Size > maxSize {
+			app.fileTooLargeResponse(w, r, maxSize)
+			return
+		}
+
+		v.AddError("file", "must not be empty")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Attachments.Insert(a)
+	if err != nil {
+		app.deleteStoredFile(r.Context(), a.StorageKey)
+
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/events/%d/attachments/%d", event.ID, a.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"attachment": a}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// downloadAttachmentHandler отдаёт содержимое файла для скачивания
+func (app *Application) downloadAttachmentHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	a, ok := app.readAttachment(w, r, params)
+	if !ok {
+		return
+	}
+
+	content, err := app.storage.Open(r.Context(), a.StorageKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	w.WriteHeader(http.StatusOK)
+
+	// Заголовки уже отправлены, поэтому ошибку можно только записать в журнал
+	_, err = io.Copy(w, content)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+func (app *Application) deleteAttachmentHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	a, ok := app.readAttachment(w, r, params)
+	if !ok {
+		return
+	}
+
+	err := app.models.Attachments.Delete(a.ID, a.EventId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteStoredFile(r.Context(), a.StorageKey)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "attachment deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readAttachment читает файл события из параметров пути.
+// Если его нет, ответ уже отправлен и возвращается false
+func (app *Application) readAttachment(w http.ResponseWriter, r *http.Request, params httprouter.Params) (*data.Attachment, bool) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(params.ByName("attachment_id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	a, err := app.models.Attachments.Get(id, event.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return a, true
+}
+
+// nextFilePart пропускает части формы до поля name
+func nextFilePart(mr *multipart.Reader, name string) (*multipart.Part, error) {
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == name {
+			return part, nil
+		}
+
+		part.Close()
+	}
+}
+
+// deleteStoredFile удаляет содержимое из хранилища. Ответ клиенту от этого
+// не зависит, поэтому ошибка только записывается в журнал
+func (app *Application) deleteStoredFile(ctx context.Context, key string) {
+	err := app.storage.Delete(ctx, key)
+	if err != nil {
+		app.logger.Error(err.Error(), slog.String("storage_key", key))
+	}
+}
+
+// deleteOrphanedAttachments удаляет из хранилища файлы окончательно удалённых событий
+func (app *Application) deleteOrphanedAttachments(ctx context.Context) {
+	for ctx.Err() == nil {
+		orphaned, err := app.models.Attachments.GetOrphaned(orphanedBatch)
+		if err != nil {
+			app.logger.Error(err.Error())
+			return
+		}
+
+		for _, a := range orphaned {
+			err = app.storage.Delete(ctx, a.StorageKey)
+			if err == nil {
+				err = app.models.Attachments.DeleteOrphaned(a.ID)
+			}
+			if err != nil {
+				app.logger.Error(err.Error(), slog.String("storage_key", a.StorageKey))
+				return
+			}
+		}
+
+		if len(orphaned) < orphanedBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/validation"
+	"net/http"
+)
+
+// Операции пакетного запроса
+const (
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+)
+
+// batchPermissions права, необходимые для каждой операции пакета
+var batchPermissions = map[string]string{
+	batchCreate: "events:create",
+	batchUpdate: "events:update",
+	batchDelete: "events:delete",
+}
+
+// batchOperation операция пакета. Для create и update поля события передаются в event,
+// как в теле запросов POST /v1/events и PATCH /v1/events/:id
+type batchOperation struct {
+	Op    string          `json:"op"`
+	ID    int64           `json:"id"`
+	Event json.RawMessage `json:"event"`
+}
+
+// batchResult результат одной операции пакета
+type batchResult struct {
+	Index  int               `json:"index"`
+	Op     string            `json:"op"`
+	Status int               `json:"status"`
+	ID     int64             `json:"id,omitempty"`
+	Event  *data.Event       `json:"event,omitempty"`
+	Errors map[string]string `json:"errors,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// batchItemError ошибка клиента в одной операции. Она отменяет только эту операцию,
+// остальные ошибки прерывают весь пакет
+type batchItemError struct {
+	status  int
+	message string
+	errors  map[string]string
+}
+
+func (e *batchItemError) Error() string {
+	if e.message != "" {
+		return e.message
+	}
+
+	return fmt.Sprintf("operation failed with status %d", e.status)
+}
+
+func batchValidationError(v *validation.Validator) error {
+	return &batchItemError{status: http.StatusUnprocessableEntity, errors: v.Errors}
+}
+
+// batchEventsHandler выполняет операции над событиями в одной транзакции.
+// В режиме atomic изменения сохраняются, только если успешны все операции,
+// в режиме best_effort сохраняются успешные операции.
+// Права проверяются для каждой операции: операция без нужного права завершается с 403.
+// Обработчик доступен по адресу /v1/batch/events, а не /v1/events/batch: httprouter
+// не допускает статический сегмент рядом с параметром в /v1/events/:id
+func (app *Application) batchEventsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var input struct {
+		Mode       string           `json:"mode"`
+		Operations []batchOperation `json:"operations"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Mode == "" {
+		input.Mode = data.BatchAtomic
+	}
+
+	v := validation.New()
+
+	v.Check(validation.In(input.Mode, data.BatchAtomic, data.BatchBestEffort), "mode", "must be atomic or best_effort")
+	v.Check(len(input.Operations) >= 1, "operations", "must contain at least 1 operation")
+	v.Check(len(input.Operations) <= data.MaxBatchOperations, "operations",
+		fmt.Sprintf("must not contain more than %d operations", data.MaxBatchOperations))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+	permissions := app.ctxGetPermissions(r)
+
+	results := make([]*batchResult, len(input.Operations))
+	failed := 0
+
+	err = app.models.Events.Batch(func(b *data.EventBatch) (bool, error) {
+		for i, op := range input.Operations {
+			result := &batchResult{Index: i, Op: op.Op, ID: op.ID}
+			results[i] = result
+
+			err := b.Do(func() error {
+				return app.runBatchOperation(b, op, user.ID, permissions, result)
+			})
+
+			var itemErr *batchItemError
+
+			switch {
+			case errors.As(err, &itemErr):
+				failed++
+				result.Status = itemErr.status
+				result.Event = nil
+				result.Errors = itemErr.errors
+				result.Error = itemErr.message
+			case err != nil:
+				return false, err
+			}
+		}
+
+		return failed == 0 || input.Mode == data.BatchBestEffort, nil
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	committed := failed == 0 || input.Mode == data.BatchBestEffort
+
+	if committed {
+		for _, result := range results {
+			if result.Op == batchUpdate && result.Event != nil {
+				app.rescheduleReminders(r, result.Event)
+			}
+		}
+	}
+
+	status := http.StatusOK
+	if !committed {
+		status = http.StatusUnprocessableEntity
+	}
+
+	err = app.writeJSON(w, status, envelope{"committed": committed, "mode": input.Mode, "results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// runBatchOperation выполняет операцию op и заполняет result. Ошибки клиента
+// возвращаются как *batchItemError
+func (app *Application) runBatchOperation(b *data.EventBatch, op batchOperation, userId int64,
+	permissions data.Permissions, result *batchResult) error {
+	if permission, ok := batchPermissions[op.Op]; ok && !permissions.Include(permission) {
+		return &batchItemError{status: http.StatusForbidden,
+			message: "you do not have the necessary permissions to access this resource"}
+	}
+
+	switch op.Op {
+	case batchCreate:
+		return app.batchCreateEvent(b, op, userId, result)
+	case batchUpdate:
+		return app.batchUpdateEvent(b, op, userId, result)
+	case batchDelete:
+		err := b.Delete(op.ID, userId)
+		if err != nil {
+			return batchModelError(err)
+		}
+
+		result.Status = http.StatusOK
+		return nil
+	default:
+		v := validation.New()
+		v.AddError("op", "must be one of create, update, delete")
+		return batchValidationError(v)
+	}
+}
+
+func (app *Application) batchCreateEvent(b *data.EventBatch, op batchOperation, userId int64, result *batchResult) error {
+	var input eventCreateInput
+
+	err := decodeBatchEvent(op.Event, &input)
+	if err != nil {
+		return err
+	}
+
+	e := input.event(userId)
+
+	v := validation.New()
+
+	data.ValidateTagIds(v, input.TagIds)
+
+	if data.ValidateEvent(v, e); !v.Valid() {
+		return batchValidationError(v)
+	}
+
+	err = b.Insert(e)
+	if err != nil {
+		return batchModelError(err)
+	}
+
+	if len(input.TagIds) > 0 {
+		err = b.SetTags(e, input.TagIds)
+		if err != nil {
+			return batchModelError(err)
+		}
+	}
+
+	result.Status = http.StatusCreated
+	result.ID = e.ID
+	result.Event = e
+
+	return nil
+}
+
+func (app *Application) batchUpdateEvent(b *data.EventBatch, op batchOperation, userId int64, result *batchResult) error {
+	event, err := b.Get(op.ID, userId)
+	if err != nil {
+		return batchModelError(err)
+	}
+
+	var input eventUpdateInput
+
+	err = decodeBatchEvent(op.Event, &input)
+	if err != nil {
+		return err
+	}
+
+	input.apply(event)
+
+	v := validation.New()
+
+	if input.TagIds != nil {
+		data.ValidateTagIds(v, *input.TagIds)
+	}
+
+	if data.ValidateEvent(v, event); !v.Valid() {
+		return batchValidationError(v)
+	}
+
+	if input.CardId != nil {
+		exists, err := b.CardExists(event.CardId, userId)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			v.AddError("card_id", "card is not present in the table")
+			return batchValidationError(v)
+		}
+	}
+
+	err = b.Update(event)
+	if err != nil {
+		return batchModelError(err)
+	}
+
+	if input.TagIds != nil {
+		err = b.SetTags(event, *input.TagIds)
+		if err != nil {
+			return batchModelError(err)
+		}
+	}
+
+	result.Status = http.StatusOK
+	result.Event = event
+
+	return nil
+}
+
+// decodeBatchEvent разбирает поля события операции так же строго, как readJSON
+func decodeBatchEvent(js json.RawMessage, dst any) error {
+	if len(js) == 0 {
+		v := validation.New()
+		v.AddError("event", "must be provided")
+		return batchValidationError(v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		return &batchItemError{status: http.StatusBadRequest, message: fmt.Sprintf("body contains badly-formed event: %v", err)}
+	}
+
+	return nil
+}
+
+// batchModelError переводит ошибки модели в ошибки операции так же,
+// как это делают обработчики отдельных запросов
+func batchModelError(err error) error {
+	v := validation.New()
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return &batchItemError{status: http.StatusNotFound, message: "the request resource could not be found"}
+	case errors.Is(err, data.ErrEditConflict):
+		return &batchItemError{status: http.StatusConflict,
+			message: "unable to update the record due to edit conflict, please try again"}
+	case errors.Is(err, data.ErrDuplicateTitle):
+		v.AddError("title", "event with this title already exists")
+	case errors.Is(err, data.ErrCardConstraint):
+		v.AddError("card_id", "card is not present in the table")
+	case errors.Is(err, data.ErrTagNotFound):
+		v.AddError("tag_ids", "must contain only existing tags")
+	default:
+		return err
+	}
+
+	return batchValidationError(v)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/validation"
+	"net/http"
+)
+
+// maxBlockRetries количество попыток применить изменение блока, если событие
+// было изменено параллельно, а клиент не передал версию
+const maxBlockRetries = 3
+
+// blockChange изменяет блоки события. Ошибки в данных клиента добавляются в v,
+// ErrRecordNotFound означает, что изменяемого блока нет
+type blockChange func(blocks data.Blocks, v *validation.Validator) (data.Blocks, error)
+
+// appendBlockHandler добавляет блок в позицию position, по умолчанию в конец
+func (app *Application) appendBlockHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var input struct {
+		Block    *data.Block `json:"block"`
+		Position *int        `json:"position"`
+		Version  *int64      `json:"version"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validation.New()
+
+	if v.Check(input.Block != nil, "block", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	position := -1
+	if input.Position != nil {
+		position = *input.Position
+	}
+
+	event, ok := app.changeBlocks(w, r, params, input.Version, func(blocks data.Blocks, v *validation.Validator) (data.Blocks, error) {
+		v.Check(position >= -1 && position <= len(blocks), "position", "must be within the list of blocks")
+
+		return blocks.Insert(input.Block, position), nil
+	})
+	if !ok {
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/events/%d/blocks/%s", event.ID, input.Block.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"event": event}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateBlockHandler изменяет поля блока и, если передана position, перемещает его
+func (app *Application) updateBlockHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var input struct {
+		Type     *string `json:"type"`
+		Text     *string `json:"text"`
+		Checked  *bool   `json:"checked"`
+		Level    *int    `json:"level"`
+		Language *string `json:"language"`
+		URL      *string `json:"url"`
+		Position *int    `json:"position"`
+		Version  *int64  `json:"version"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	id := params.ByName("block_id")
+
+	event, ok := app.changeBlocks(w, r, params, input.Version, func(blocks data.Blocks, v *validation.Validator) (data.Blocks, error) {
+		i := blocks.Index(id)
+		if i < 0 {
+			return nil, data.ErrRecordNotFound
+		}
+
+		block := *blocks[i]
+
+		// Поля, относящиеся к прежнему типу блока, при смене типа сбрасываются
+		if input.Type != nil && *input.Type != block.Type {
+			block = data.Block{ID: block.ID, Type: *input.Type, Text: block.Text}
+		}
+
+		if input.Text != nil {
+			block.Text = *input.Text
+		}
+
+		if input.Checked != nil {
+			block.Checked = input.Checked
+		}
+
+		if input.Level != nil {
+			block.Level = *input.Level
+		}
+
+		if input.Language != nil {
+			block.Language = *input.Language
+		}
+
+		if input.URL != nil {
+			block.URL = *input.URL
+		}
+
+		blocks[i] = &block
+
+		if input.Position == nil {
+			return blocks, nil
+		}
+
+		v.Check(*input.Position >= 0 && *input.Position < len(blocks), "position", "must be within the list of blocks")
+
+		return blocks.Remove(i).Insert(&block, *input.Position), nil
+	})
+	if !ok {
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteBlockHandler удаляет блок. Версию события можно передать в параметре version
+func (app *Application) deleteBlockHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	v := validation.New()
+
+	version := app.readVersion(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	id := params.ByName("block_id")
+
+	event, ok := app.changeBlocks(w, r, params, version, func(blocks data.Blocks, v *validation.Validator) (data.Blocks, error) {
+		i := blocks.Index(id)
+		if i < 0 {
+			return nil, data.ErrRecordNotFound
+		}
+
+		return blocks.Remove(i), nil
+	})
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// reorderBlocksHandler задаёт новый порядок блоков. Список order должен
+// содержать идентификаторы всех блоков события ровно по одному разу
+func (app *Application) reorderBlocksHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var input struct {
+		Order   []string `json:"order"`
+		Version *int64   `json:"version"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event, ok := app.changeBlocks(w, r, params, input.Version, func(blocks data.Blocks, v *validation.Validator) (data.Blocks, error) {
+		v.Check(len(input.Order) == len(blocks) && validation.Unique(input.Order), "order",
+			"must contain every block id exactly once")
+
+		reordered := make(data.Blocks, 0, len(blocks))
+
+		for _, id := range input.Order {
+			i := blocks.Index(id)
+			if i < 0 {
+				v.AddError("order", "must contain every block id exactly once")
+				break
+			}
+
+			reordered = append(reordered, blocks[i])
+		}
+
+		return reordered, nil
+	})
+	if !ok {
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// changeBlocks применяет change к блокам события и сохраняет их, увеличивая версию события.
+// Если клиент передал version, изменение применяется только к этой версии. Иначе при
+// параллельном изменении события оно повторяется на свежих данных до maxBlockRetries раз.
+// Если изменение не удалось, ответ уже отправлен и возвращается false
+func (app *Application) changeBlocks(w http.ResponseWriter, r *http.Request, params httprouter.Params,
+	version *int64, change blockChange) (*data.Event, bool) {
+
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	user := app.ctxGetUser(r)
+
+	for attempt := 1; ; attempt++ {
+		event, err := app.models.Events.Get(id, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return nil, false
+		}
+
+		if version != nil && *version != event.Version {
+			app.editConflictResponse(w, r)
+			return nil, false
+		}
+
+		v := validation.New()
+
+		blocks, err := change(event.TextBlocks, v)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return nil, false
+		}
+
+		if data.ValidateBlocks(v, blocks); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return nil, false
+		}
+
+		event.TextBlocks = blocks
+
+		err = app.models.Events.UpdateBlocks(event)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict) && version == nil && attempt < maxBlockRetries:
+				continue
+			case errors.Is(err, data.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return nil, false
+		}
+
+		return event, true
+	}
+}
+
+// readVersion читает необязательную версию события из параметра version
+func (app *Application) readVersion(r *http.Request, v *validation.Validator) *int64 {
+	qs := r.URL.Query()
+
+	if !qs.Has("version") {
+		return nil
+	}
+
+	version := int64(app.readInt(qs, "version", 0, v))
+
+	return &version
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/ical"
+	"library/internal/validation"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// calendarDomain домен в UID событий календаря. UID зависит только от идентификатора
+// события, поэтому повторный импорт обновляет события в календаре, а не дублирует их
+const calendarDomain = "todo.goserv.ru"
+
+// exportEventsHandler отдаёт события пользователя в формате iCalendar.
+// Параметры выборки те же, что у listEventHandler, кроме постраничного вывода
+func (app *Application) exportEventsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	v := validation.New()
+
+	filters := app.readEventFilters(r.URL.Query(), v)
+
+	if data.ValidateEventFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	events, err := app.models.Events.GetCalendar(user.ID, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTooManyEvents):
+			app.tooManyEventsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeCalendar(w, r, "Events", events)
+}
+
+// exportCardEventsHandler отдаёт события карточки в формате iCalendar
+func (app *Application) exportCardEventsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validation.New()
+
+	filters := app.readCardEventFilters(r.URL.Query(), id, v)
+
+	if data.ValidateEventFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	card, err := app.models.Cards.Get(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	events, err := app.models.Events.GetCalendar(user.ID, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTooManyEvents):
+			app.tooManyEventsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeCalendar(w, r, card.Title, events)
+}
+
+// writeCalendar отправляет events календарём с названием name
+func (app *Application) writeCalendar(w http.ResponseWriter, r *http.Request, name string, events []*data.CalendarEvent) {
+	var buf bytes.Buffer
+
+	cal := ical.NewWriter(&buf)
+	stamp := time.Now()
+
+	cal.Begin("VCALENDAR")
+	cal.Property("VERSION", "2.0")
+	cal.Text("PRODID", "-//"+calendarDomain+"//Todo API//EN")
+	cal.Property("CALSCALE", "GREGORIAN")
+	cal.Property("METHOD", "PUBLISH")
+	cal.Text("X-WR-CALNAME", name)
+
+	for _, t := range calendarTimezones(events) {
+		cal.Timezone(t)
+	}
+
+	for _, event := range events {
+		writeCalendarEvent(cal, event, stamp)
+	}
+
+	cal.End("VCALENDAR")
+
+	if err := cal.Err(); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="events.ics"`)
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write(buf.Bytes())
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// writeCalendarEvent записывает событие как VEVENT. Изменённые повторения серии
+// записываются отдельными VEVENT с тем же UID и RECURRENCE-ID, отменённые - в EXDATE
+func writeCalendarEvent(cal *ical.Writer, event *data.CalendarEvent, stamp time.Time) {
+	cal.Begin("VEVENT")
+
+	writeEventProperties(cal, event.Event, stamp)
+
+	if event.Recurrence != "" {
+		cal.Property("RRULE", calendarRule(event.Event))
+
+		for _, d := range event.Cancelled {
+			value, params := occurrenceValue(event.Event, d)
+			cal.Property("EXDATE", value, params...)
+		}
+	}
+
+	cal.End("VEVENT")
+
+	for _, occ := range event.Overrides {
+		cal.Begin("VEVENT")
+
+		writeEventProperties(cal, occ, stamp)
+
+		value, params := occurrenceValue(event.Event, occ.OccurrenceDate.Time)
+		cal.Property("RECURRENCE-ID", value, params...)
+
+		cal.End("VEVENT")
+	}
+}
+
+func writeEventProperties(cal *ical.Writer, event *data.Event, stamp time.Time) {
+	cal.Property("UID", calendarUID(event))
+	cal.Property("DTSTAMP", ical.FormatDateTime(stamp))
+
+	if !event.CreatedAt.IsZero() {
+		cal.Property("CREATED", ical.FormatDateTime(event.CreatedAt))
+	}
+
+	// Событие без времени длится весь день: DTEND - следующий день, не входящий в событие.
+	// Время записывается в поясе события, а не в UTC: правило повторения считает дни
+	// по местному времени, и в UTC повторение может сместиться на соседний день
+	if event.Date.Timed {
+		value, tz := ical.FormatLocalDateTime(event.Date.Time)
+		cal.Property("DTSTART", value, tz)
+	} else {
+		cal.Property("DTSTART", ical.FormatDate(event.Date.Time), ical.Param{Name: "VALUE", Value: "DATE"})
+		cal.Property("DTEND", ical.FormatDate(event.Date.AddDate(0, 0, 1)), ical.Param{Name: "VALUE", Value: "DATE"})
+	}
+
+	cal.Property("SEQUENCE", fmt.Sprint(max(event.Version-1, 0)))
+	cal.Text("SUMMARY", event.Title)
+
+	if description := calendarDescription(event); description != "" {
+		cal.Text("DESCRIPTION", description)
+	}
+
+	if event.Status == data.StatusCancelled {
+		cal.Property("STATUS", "CANCELLED")
+	} else {
+		cal.Property("STATUS", "CONFIRMED")
+	}
+
+	if priority := calendarPriority(event.Priority); priority != 0 {
+		cal.Property("PRIORITY", fmt.Sprint(priority))
+	}
+
+	if len(event.Tags) > 0 {
+		names := make([]string, len(event.Tags))
+		for i, tag := range event.Tags {
+			names[i] = tag.Name
+		}
+		cal.TextList("CATEGORIES", names)
+	}
+}
+
+// calendarUID UID события в календаре. Импортированное событие сохраняет UID
+// исходного календаря, чтобы календарь узнал его при повторном импорте
+func calendarUID(event *data.Event) string {
+	if event.ICalUID != "" {
+		return event.ICalUID
+	}
+
+	return fmt.Sprintf("event-%d@%s", event.ID, calendarDomain)
+}
+
+// calendarDescription описание события вместе с текстом его блоков
+func calendarDescription(event *data.Event) string {
+	var lines []string
+
+	if event.Description != "" {
+		lines = append(lines, event.Description)
+	}
+
+	for _, block := range event.TextBlocks {
+		switch block.Type {
+		case data.BlockChecklist:
+			mark := "[ ] "
+			if block.Checked != nil && *block.Checked {
+				mark = "[x] "
+			}
+			lines = append(lines, mark+block.Text)
+		case data.BlockLink:
+			lines = append(lines, fmt.Sprintf("%s (%s)", block.Text, block.URL))
+		default:
+			lines = append(lines, block.Text)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// calendarPriority переводит приоритет в шкалу RFC 5545, где 1 - наивысший, а 0 - не задан
+func calendarPriority(p data.Priority) int {
+	switch p {
+	case data.PriorityUrgent:
+		return 1
+	case data.PriorityHigh:
+		return 3
+	case data.PriorityMedium:
+		return 5
+	case data.PriorityLow:
+		return 7
+	default:
+		return 0
+	}
+}
+
+// calendarRule правило повторения события. Для события со временем UNTIL
+// должен быть моментом времени, поэтому берётся конец последнего дня
+func calendarRule(event *data.Event) string {
+	rule, err := event.Rule()
+	if err != nil || rule == nil {
+		return event.Recurrence
+	}
+
+	if !event.Date.Timed || rule.Until.IsZero() {
+		return rule.String()
+	}
+
+	until := rule.Until
+	end := time.Date(until.Year(), until.Month(), until.Day(), 23, 59, 59, 0, event.Date.Location())
+
+	untimed := *rule
+	untimed.Until = time.Time{}
+
+	return untimed.String() + ";UNTIL=" + ical.FormatDateTime(end)
+}
+
+// occurrenceValue значение RECURRENCE-ID или EXDATE для повторения серии в день d.
+// Тип значения и часовой пояс должны совпадать с DTSTART серии
+func occurrenceValue(series *data.Event, d time.Time) (string, []ical.Param) {
+	if !series.Date.Timed {
+		return ical.FormatDate(d), []ical.Param{{Name: "VALUE", Value: "DATE"}}
+	}
+
+	start := series.Date.Time
+	t := time.Date(d.Year(), d.Month(), d.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+
+	value, tz := ical.FormatLocalDateTime(t)
+
+	return value, []ical.Param{tz}
+}
+
+// calendarTimezones возвращает по одному моменту времени для каждого часового пояса,
+// в котором записываются события со временем, чтобы описать пояса в VTIMEZONE
+func calendarTimezones(events []*data.CalendarEvent) []time.Time {
+	var zones []time.Time
+
+	seen := map[string]bool{}
+
+	add := func(event *data.Event) {
+		if !event.Date.Timed {
+			return
+		}
+
+		tzid := ical.OffsetTZID(event.Date.Time)
+		if !seen[tzid] {
+			seen[tzid] = true
+			zones = append(zones, event.Date.Time)
+		}
+	}
+
+	for _, event := range events {
+		add(event.Event)
+
+		for _, occ := range event.Overrides {
+			add(occ)
+		}
+	}
+
+	return zones
+}
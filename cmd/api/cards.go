@@ -7,6 +7,8 @@ import (
 	"library/internal/data"
 	"library/internal/validation"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 func (app *Application) showCardHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
@@ -16,7 +18,18 @@ func (app *Application) showCardHandler(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	card, err := app.models.Cards.Get(id)
+	v := validation.New()
+
+	filters := app.readCardEventFilters(r.URL.Query(), id, v)
+
+	if data.ValidateEventFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	card, err := app.models.Cards.Get(id, user.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -26,7 +39,130 @@ func (app *Application) showCardHandler(w http.ResponseWriter, r *http.Request,
 		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"card": card}, nil)
+
+	events, metadata, err := app.models.Events.GetAll(user.ID, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTooManyEvents):
+			app.tooManyEventsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	card.Events = events
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"card": card, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) listCardEventsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validation.New()
+
+	filters := app.readCardEventFilters(r.URL.Query(), id, v)
+
+	if data.ValidateEventFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	exists, err := app.models.Cards.Exists(id, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !exists {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	events, metadata, err := app.models.Events.GetAll(user.ID, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTooManyEvents):
+			app.tooManyEventsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "events": events}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readCardEventFilters читает параметры выборки событий одной карточки.
+// Архивные события не скрываются, так как карточка запрошена явно
+func (app *Application) readCardEventFilters(qs url.Values, cardId int64, v *validation.Validator) data.EventFilters {
+	var f data.EventFilters
+
+	f.CardIds = []int64{cardId}
+	f.IncludeArchived = true
+	f.Title = app.readString(qs, "title", "")
+	f.Query = app.readString(qs, "q", "")
+	f.Language = app.readString(qs, "lang", app.config.Search.Language)
+	f.DateFrom.Time = app.readDate(qs, "date_from", time.Time{}, v)
+	f.DateTo.Time = app.readDate(qs, "date_to", time.Time{}, v)
+	f.Statuses = app.readCSV(qs, "status", nil)
+	f.Tags = app.readCSV(qs, "tag", nil)
+	f.TagMode = app.readString(qs, "tag_mode", data.TagModeAny)
+	f.Sort = app.readString(qs, "sort", "position")
+	f.Page = app.readInt(qs, "page", 1, v)
+	f.PageSize = app.readInt(qs, "page_size", 20, v)
+	f.SortSafeList = []string{"id", "title", "date", "priority", "position", "relevance",
+		"-id", "-title", "-date", "-priority", "-position"}
+
+	return f
+}
+
+func (app *Application) listCardHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var input struct {
+		Title       string
+		Archived    bool
+		EventsCount bool
+		data.Filters
+	}
+
+	v := validation.New()
+
+	qs := r.URL.Query()
+
+	input.Title = app.readString(qs, "title", "")
+	input.Archived = app.readBool(qs, "archived", false, v)
+	input.EventsCount = app.readBool(qs, "events_count", false, v)
+	input.Sort = app.readString(qs, "sort", "id")
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 5, v)
+	input.SortSafeList = []string{"id", "title", "created_at", "-id", "-title", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	cards, metadata, err := app.models.Cards.GetAll(input.Title, input.Archived, input.EventsCount, user.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "cards": cards}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -43,9 +179,11 @@ func (app *Application) createCardHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	user := app.ctxGetUser(r)
+
 	c := &data.Card{
-		Title:  input.Title,
-		Events: data.Events{},
+		Title:   input.Title,
+		OwnerId: user.ID,
 	}
 
 	v := validation.New()
@@ -79,7 +217,9 @@ func (app *Application) updateCardHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	card, err := app.models.Cards.Get(id)
+	user := app.ctxGetUser(r)
+
+	card, err := app.models.Cards.Get(id, user.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -91,7 +231,8 @@ func (app *Application) updateCardHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	var input struct {
-		Title *string `json:"title"`
+		Title    *string `json:"title"`
+		Archived *bool   `json:"archived"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -104,6 +245,10 @@ func (app *Application) updateCardHandler(w http.ResponseWriter, r *http.Request
 		card.Title = *input.Title
 	}
 
+	if input.Archived != nil {
+		card.Archived = *input.Archived
+	}
+
 	v := validation.New()
 	if data.ValidateCard(v, card); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
@@ -127,3 +272,51 @@ func (app *Application) updateCardHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 }
+
+func (app *Application) deleteCardHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Strategy     string
+		TargetCardId int64
+	}
+
+	v := validation.New()
+
+	qs := r.URL.Query()
+
+	input.Strategy = app.readString(qs, "strategy", data.DeleteRestrict)
+	input.TargetCardId = int64(app.readInt(qs, "target_card_id", 0, v))
+
+	if data.ValidateCardDeletion(v, id, input.Strategy, input.TargetCardId); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	err = app.models.Cards.Delete(id, user.ID, input.Strategy, input.TargetCardId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrCardNotEmpty):
+			app.cardNotEmptyResponse(w, r)
+		case errors.Is(err, data.ErrCardConstraint):
+			v.AddError("target_card_id", "card is not present in the table")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "card deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/validation"
+	"net/http"
+	"strconv"
+)
+
+// listCommentsHandler возвращает комментарии верхнего уровня события или,
+// если передан parent_id, ответы на этот комментарий
+func (app *Application) listCommentsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ParentId *int64
+		data.Filters
+	}
+
+	v := validation.New()
+
+	qs := r.URL.Query()
+
+	if qs.Has("parent_id") {
+		parentId := int64(app.readInt(qs, "parent_id", 0, v))
+		v.Check(parentId > 0, "parent_id", "must be a positive id")
+		input.ParentId = &parentId
+	}
+
+	input.Sort = app.readString(qs, "sort", "created_at")
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.SortSafeList = []string{"created_at", "-created_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	comments, metadata, err := app.models.Comments.GetAll(event.ID, input.ParentId, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "comments": comments}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) createCommentHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Body     string `json:"body"`
+		ParentId *int64 `json:"parent_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	comment := &data.Comment{
+		EventId:  event.ID,
+		ParentId: input.ParentId,
+		Author:   data.Author{ID: user.ID, Name: user.Name},
+		Body:     input.Body,
+	}
+
+	v := validation.New()
+
+	if data.ValidateComment(v, comment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Insert(comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidParent):
+			v.AddError("parent_id", "comment is not present in the event")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/events/%d/comments/%d", event.ID, comment.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"comment": comment}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateCommentHandler изменяет текст комментария, изменять его может только автор
+func (app *Application) updateCommentHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	comment, ok := app.readAuthoredComment(w, r, params)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Body    *string `json:"body"`
+		Version *int64  `json:"version"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Body != nil {
+		comment.Body = *input.Body
+	}
+
+	if input.Version != nil {
+		comment.Version = *input.Version
+	}
+
+	v := validation.New()
+
+	if data.ValidateComment(v, comment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Update(comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comment": comment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteCommentHandler удаляет комментарий вместе с ответами, удалить его может только автор
+func (app *Application) deleteCommentHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	comment, ok := app.readAuthoredComment(w, r, params)
+	if !ok {
+		return
+	}
+
+	err := app.models.Comments.Delete(comment.ID, comment.Author.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "comment deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readAuthoredComment читает комментарий события из параметров пути и проверяет,
+// что его автор - текущий пользователь. Иначе ответ уже отправлен и возвращается false
+func (app *Application) readAuthoredComment(w http.ResponseWriter, r *http.Request, params httprouter.Params) (*data.Comment, bool) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(params.ByName("comment_id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	comment, err := app.models.Comments.Get(id, event.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if comment.Author.ID != app.ctxGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return comment, true
+}
@@ -10,6 +10,7 @@ type ctxKey string
 
 const userCtxKey = ctxKey("user")
 const roleCtxKey = ctxKey("role")
+const permissionsCtxKey = ctxKey("permissions")
 
 func (app *Application) ctxSetUser(r *http.Request, user *data.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userCtxKey, user)
@@ -30,3 +31,16 @@ func (app *Application) ctxSetRole(r *http.Request, role string) *http.Request {
 
 	return r.WithContext(ctx)
 }
+
+func (app *Application) ctxSetPermissions(r *http.Request, permissions data.Permissions) *http.Request {
+	ctx := context.WithValue(r.Context(), permissionsCtxKey, permissions)
+
+	return r.WithContext(ctx)
+}
+
+// ctxGetPermissions возвращает права пользователя, загруженные в authenticate.
+// У анонимного пользователя прав нет
+func (app *Application) ctxGetPermissions(r *http.Request) data.Permissions {
+	permissions, _ := r.Context().Value(permissionsCtxKey).(data.Permissions)
+	return permissions
+}
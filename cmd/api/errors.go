@@ -9,7 +9,7 @@ import (
 func (app *Application) logError(r *http.Request, err error) {
 	app.logger.Error(err.Error(),
 		slog.String("request_method", r.Method),
-		slog.String("request_url", r.URL.String()),
+		slog.String("request_url", redactURL(r.URL).String()),
 	)
 }
 
@@ -55,6 +55,27 @@ func (app *Application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (app *Application) fileTooLargeResponse(w http.ResponseWriter, r *http.Request, maxSize int64) {
+	message := fmt.Sprintf("the file must not be larger than %d bytes", maxSize)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, message)
+}
+
+// tooManyEventsResponse сообщает, что выборку нужно сузить, чтобы вернуть её целиком
+func (app *Application) tooManyEventsResponse(w http.ResponseWriter, r *http.Request) {
+	errors := map[string]string{"date_to": "too many events in the date range, narrow it down"}
+	app.failedValidationResponse(w, r, errors)
+}
+
+func (app *Application) cardNotEmptyResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the card still contains events, choose the cascade or move delete strategy"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *Application) builtinRoleResponse(w http.ResponseWriter, r *http.Request) {
+	message := "built-in roles cannot be deleted or lose their required permissions"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
 func (app *Application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
@@ -7,40 +7,72 @@ import (
 	"library/internal/data"
 	"library/internal/validation"
 	"net/http"
+	"net/url"
 	"time"
 )
 
-func (app *Application) createEventHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-
-	var input struct {
-		Title       string    `json:"title"`
-		Description string    `json:"description"`
-		TextBlocks  []string  `json:"text_blocks"`
-		Date        data.Date `json:"date"`
-		CardId      int64     `json:"card_id"`
-	}
-	err := app.readJSON(w, r, &input)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
+// eventCreateInput поля нового события
+type eventCreateInput struct {
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	TextBlocks  data.Blocks   `json:"text_blocks"`
+	Date        data.Date     `json:"date"`
+	CardId      int64         `json:"card_id"`
+	Status      string        `json:"status"`
+	Priority    data.Priority `json:"priority"`
+	Recurrence  string        `json:"recurrence"`
+	TagIds      []int64       `json:"tag_ids"`
+}
 
+func (input eventCreateInput) event(ownerId int64) *data.Event {
 	e := &data.Event{
 		Title:       input.Title,
 		Description: input.Description,
 		TextBlocks:  input.TextBlocks,
 		Date:        input.Date,
 		CardId:      input.CardId,
+		OwnerId:     ownerId,
+		Status:      input.Status,
+		Priority:    input.Priority,
+		Recurrence:  input.Recurrence,
+		Tags:        data.Tags{},
+	}
+
+	if e.Status == "" {
+		e.Status = data.StatusTodo
 	}
 
+	return e
+}
+
+func (app *Application) createEventHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+
+	var input eventCreateInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	e := input.event(user.ID)
+
 	v := validation.New()
 
+	data.ValidateTagIds(v, input.TagIds)
+
 	if data.ValidateEvent(v, e); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Events.Insert(e)
+	if !app.checkTagsOwned(w, r, v, input.TagIds, user.ID) {
+		return
+	}
+
+	err = app.models.Events.InsertWithTags(e, input.TagIds)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateTitle):
@@ -50,6 +82,9 @@ func (app *Application) createEventHandler(w http.ResponseWriter, r *http.Reques
 		case errors.Is(err, data.ErrCardConstraint):
 			v.AddError("card_id", "card is not present in the table")
 			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrTagNotFound):
+			v.AddError("tag_ids", "must contain only existing tags")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -76,7 +111,9 @@ func (app *Application) showEventHandler(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	event, err := app.models.Events.Get(id)
+	user := app.ctxGetUser(r)
+
+	event, err := app.models.Events.Get(id, user.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -93,6 +130,63 @@ func (app *Application) showEventHandler(w http.ResponseWriter, r *http.Request,
 	}
 }
 
+// eventUpdateInput поля события, которые можно изменить запросом PATCH
+type eventUpdateInput struct {
+	Title       *string        `json:"title"`
+	Description *string        `json:"description"`
+	Date        *data.Date     `json:"date"`
+	TextBlocks  data.Blocks    `json:"text_blocks"`
+	Version     *int64         `json:"version"`
+	CardId      *int64         `json:"card_id"`
+	Status      *string        `json:"status"`
+	Priority    *data.Priority `json:"priority"`
+	Recurrence  *string        `json:"recurrence"`
+	// TagIds заменяет набор меток события, если передан
+	TagIds *[]int64 `json:"tag_ids"`
+}
+
+func (input eventUpdateInput) apply(event *data.Event) {
+	if input.Title != nil {
+		event.Title = *input.Title
+	}
+
+	if input.Description != nil {
+		event.Description = *input.Description
+	}
+
+	if input.Date != nil {
+		event.Date = *input.Date
+	}
+
+	if input.Version != nil {
+		event.Version = *input.Version
+	}
+
+	if input.TextBlocks != nil {
+		event.TextBlocks = input.TextBlocks
+	}
+
+	if input.CardId != nil {
+		event.CardId = *input.CardId
+	}
+
+	if input.Status != nil {
+		event.Status = *input.Status
+	}
+
+	if input.Priority != nil {
+		event.Priority = *input.Priority
+	}
+
+	if input.Recurrence != nil {
+		event.Recurrence = *input.Recurrence
+	}
+}
+
+// updateEventHandler изменяет событие. Для повторяющихся событий параметр scope
+// задаёт, что изменяется: вся серия (all), одно повторение (occurrence)
+// или повторения начиная с указанного (following). День повторения передаётся
+// в параметре occurrence
 func (app *Application) updateEventHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 
 	id, err := app.readID(params)
@@ -101,7 +195,9 @@ func (app *Application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	event, err := app.models.Events.Get(id)
+	user := app.ctxGetUser(r)
+
+	event, err := app.models.Events.Get(id, user.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -112,14 +208,7 @@ func (app *Application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	var input struct {
-		Title       *string    `json:"title"`
-		Description *string    `json:"description"`
-		Date        *data.Date `json:"date"`
-		TextBlocks  []string   `json:"text_blocks"`
-		Version     *int64     `json:"version"`
-		CardId      *int64     `json:"card_id"`
-	}
+	var input eventUpdateInput
 
 	err = app.readJSON(w, r, &input)
 	if err != nil {
@@ -127,49 +216,115 @@ func (app *Application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if input.Title != nil {
-		event.Title = *input.Title
-	}
+	v := validation.New()
 
-	if input.Description != nil {
-		event.Description = *input.Description
-	}
+	qs := r.URL.Query()
+	scope := app.readString(qs, "scope", data.ScopeAll)
+	occurrence := app.readDate(qs, "occurrence", time.Time{}, v)
 
-	if input.Date != nil {
-		event.Date = *input.Date
+	if data.ValidateEventScope(v, event, scope, occurrence); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
 	}
 
-	if input.Version != nil {
-		event.Version = *input.Version
+	if scope == data.ScopeOccurrence {
+		app.updateEventOccurrence(w, r, event, occurrence, input)
+		return
 	}
 
-	if input.TextBlocks != nil {
-		event.TextBlocks = input.TextBlocks
+	// Изменение "этого и следующих" начиная с первого повторения равносильно изменению всей серии
+	var original *data.Event
+
+	if scope == data.ScopeFollowing && occurrence.Format("2006-01-02") != event.Date.Format("2006-01-02") {
+		if input.Version != nil {
+			event.Version = *input.Version
+		}
+
+		original = event
+
+		event, err = original.Split(occurrence)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
 	}
-	app.logger.Info(fmt.Sprintf("Card ID: %d", input.CardId))
 
-	if input.CardId != nil {
-		event.CardId = *input.CardId
+	input.apply(event)
+
+	// Новая серия сохраняет метки исходной, если они не переданы явно
+	tagIds := input.TagIds
+	if original != nil && tagIds == nil {
+		ids := make([]int64, len(original.Tags))
+		for i, tag := range original.Tags {
+			ids[i] = tag.ID
+		}
+		tagIds = &ids
 	}
 
-	v := validation.New()
+	if tagIds != nil {
+		data.ValidateTagIds(v, *tagIds)
+	}
 
 	if data.ValidateEvent(v, event); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Events.Update(event)
+	if tagIds != nil && !app.checkTagsOwned(w, r, v, *tagIds, user.ID) {
+		return
+	}
+
+	if input.CardId != nil {
+		exists, err := app.models.Cards.Exists(event.CardId, user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !exists {
+			v.AddError("card_id", "card is not present in the table")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	// nil оставляет метки события без изменений
+	var ids []int64
+	if tagIds != nil {
+		ids = *tagIds
+	}
+
+	if original != nil {
+		err = app.models.Events.SplitSeries(original, event, occurrence, ids)
+	} else {
+		err = app.models.Events.UpdateWithTags(event, ids)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateTitle):
+			v.AddError("title", "event with this title already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrCardConstraint):
+			v.AddError("card_id", "card is not present in the table")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrTagNotFound):
+			v.AddError("tag_ids", "must contain only existing tags")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
+	rescheduled := event
+	if original != nil {
+		rescheduled = original
+	}
+
+	app.rescheduleReminders(r, rescheduled)
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -178,6 +333,73 @@ func (app *Application) updateEventHandler(w http.ResponseWriter, r *http.Reques
 
 }
 
+// updateEventOccurrence сохраняет изменения одного повторения серии, сама серия не меняется
+func (app *Application) updateEventOccurrence(w http.ResponseWriter, r *http.Request, event *data.Event,
+	occurrence time.Time, input eventUpdateInput) {
+
+	v := validation.New()
+
+	v.Check(input.TextBlocks == nil && input.CardId == nil && input.Recurrence == nil && input.TagIds == nil, "scope",
+		"only title, description, date, status and priority can be changed for a single occurrence")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.Version != nil && *input.Version != event.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	occ, err := app.models.Events.Occurrence(event, occurrence)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	input.apply(occ)
+
+	if data.ValidateEvent(v, occ); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	exception := &data.EventException{
+		EventId:        event.ID,
+		OccurrenceDate: occurrence,
+		Title:          input.Title,
+		Description:    input.Description,
+		Date:           input.Date,
+		Status:         input.Status,
+		Priority:       input.Priority,
+	}
+
+	err = app.models.Events.SaveException(exception, event.OwnerId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": occ}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteEventHandler удаляет событие. Параметры scope и occurrence имеют тот же
+// смысл, что и при изменении: отдельное повторение отменяется, а при удалении
+// "этого и следующих" серия завершается перед указанным повторением
 func (app *Application) deleteEventHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 
 	id, err := app.readID(params)
@@ -186,7 +408,20 @@ func (app *Application) deleteEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Events.Delete(id)
+	user := app.ctxGetUser(r)
+
+	v := validation.New()
+
+	qs := r.URL.Query()
+	scope := app.readString(qs, "scope", data.ScopeAll)
+	occurrence := app.readDate(qs, "occurrence", time.Time{}, v)
+
+	if scope != data.ScopeAll {
+		app.deleteEventOccurrences(w, r, id, user.ID, scope, occurrence, v)
+		return
+	}
+
+	err = app.models.Events.Delete(id, user.ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -197,46 +432,314 @@ func (app *Application) deleteEventHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "event deleted"}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "event moved to trash"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 }
 
+func (app *Application) deleteEventOccurrences(w http.ResponseWriter, r *http.Request, id, ownerId int64,
+	scope string, occurrence time.Time, v *validation.Validator) {
+
+	event, err := app.models.Events.Get(id, ownerId)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if data.ValidateEventScope(v, event, scope, occurrence); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	message := "occurrence cancelled"
+
+	switch {
+	case scope == data.ScopeOccurrence:
+		err = app.models.Events.SaveException(&data.EventException{
+			EventId:        event.ID,
+			OccurrenceDate: occurrence,
+			Cancelled:      true,
+		}, ownerId)
+
+	case occurrence.Format("2006-01-02") == event.Date.Format("2006-01-02"):
+		message = "event deleted"
+		err = app.models.Events.Delete(event.ID, ownerId)
+
+	default:
+		message = "following occurrences deleted"
+		_, err = event.Split(occurrence)
+		if err == nil {
+			err = app.models.Events.SplitSeries(event, nil, occurrence, nil)
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": message}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *Application) listEventHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// Добавлять фильтрацию по card_id?
+	v := validation.New()
+
+	input := app.readEventFilters(r.URL.Query(), v)
+
+	if data.ValidateEventFilters(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	var (
+		events   []*data.Event
+		metadata interface{}
+		err      error
+	)
+
+	if input.Keyset {
+		events, metadata, err = app.models.Events.GetAllAfter(user.ID, input)
+	} else {
+		events, metadata, err = app.models.Events.GetAll(user.ID, input)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTooManyEvents):
+			app.tooManyEventsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "events": events}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// readEventFilters читает параметры выборки событий пользователя
+func (app *Application) readEventFilters(qs url.Values, v *validation.Validator) data.EventFilters {
+	var f data.EventFilters
+
+	f.Title = app.readString(qs, "title", "")
+	f.Query = app.readString(qs, "q", "")
+	f.Language = app.readString(qs, "lang", app.config.Search.Language)
+	f.DateFrom, f.DateTo = app.readDateRange(qs, v)
+	f.CardIds = app.readIDs(qs, "card_id", v)
+	f.Statuses = app.readCSV(qs, "status", nil)
+	f.IncludeArchived = app.readBool(qs, "include_archived", false, v)
+	f.Tags = app.readCSV(qs, "tag", nil)
+	f.TagMode = app.readString(qs, "tag_mode", data.TagModeAny)
+	f.Sort = app.readString(qs, "sort", "id")
+	f.Page = app.readInt(qs, "page", 1, v)
+	f.PageSize = app.readInt(qs, "page_size", 5, v)
+	f.SortSafeList = []string{"id", "title", "date", "priority", "position", "relevance",
+		"-id", "-title", "-date", "-priority", "-position"}
+	f.Keyset, f.Cursor = app.readCursor(qs, v)
+
+	return f
+}
+
+func (app *Application) completeEventHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.changeEventStatus(w, r, params, data.StatusDone)
+}
+
+func (app *Application) reopenEventHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	app.changeEventStatus(w, r, params, data.StatusTodo)
+}
+
+// changeEventStatus переводит событие в статус status. Тело запроса должно
+// содержать версию события, которую видел клиент
+func (app *Application) changeEventStatus(w http.ResponseWriter, r *http.Request, params httprouter.Params, status string) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
 	var input struct {
-		Title string
-		Date  data.Date
-		data.Filters
+		Version *int64 `json:"version"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	v := validation.New()
 
-	qs := r.URL.Query()
+	if v.Check(input.Version != nil, "version", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 
-	input.Title = app.readString(qs, "title", "")
-	input.Date.Time = app.readDate(qs, "date", time.Now(), v)
-	input.Sort = app.readString(qs, "sort", "id")
-	input.Page = app.readInt(qs, "page", 1, v)
-	input.PageSize = app.readInt(qs, "page_size", 5, v)
-	input.SortSafeList = []string{"id", "title", "date", "-id", "-title", "-date"}
+	user := app.ctxGetUser(r)
 
-	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+	event, err := app.models.Events.Get(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if event.Version != *input.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	if status == data.StatusTodo {
+		v.Check(event.Status == data.StatusDone || event.Status == data.StatusCancelled,
+			"status", "only done or cancelled events can be reopened")
+	}
+
+	event.Status = status
+
+	if data.ValidateEventStatus(v, event); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	events, metadata, err := app.models.Events.GetAll(input.Title, input.Date, input.Filters)
+	err = app.models.Events.Update(event)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "events": events}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkTagsOwned проверяет, что все метки принадлежат пользователю.
+// Если это не так, ответ уже отправлен и возвращается false
+func (app *Application) checkTagsOwned(w http.ResponseWriter, r *http.Request, v *validation.Validator, ids []int64, userId int64) bool {
+	owned, err := app.models.Tags.Owned(ids, userId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	if !owned {
+		v.AddError("tag_ids", "must contain only existing tags")
+		app.failedValidationResponse(w, r, v.Errors)
+		return false
+	}
+
+	return true
+}
+
+// moveEventHandler перемещает событие перед (before) или после (after) другого
+// события карточки, при необходимости в другую карточку (card_id).
+// Без before и after событие становится последним в карточке
+func (app *Application) moveEventHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var input struct {
+		CardId  *int64 `json:"card_id"`
+		Before  *int64 `json:"before"`
+		After   *int64 `json:"after"`
+		Version *int64 `json:"version"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
 		return
 	}
+
+	if input.Version != nil && *input.Version != event.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	target := data.MoveTarget{CardId: event.CardId}
+
+	if input.CardId != nil {
+		target.CardId = *input.CardId
+	}
+
+	if input.Before != nil {
+		target.Before = *input.Before
+	}
+
+	if input.After != nil {
+		target.After = *input.After
+	}
+
+	v := validation.New()
+
+	if data.ValidateMoveTarget(v, event, target); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if target.CardId != event.CardId {
+		exists, err := app.models.Cards.Exists(target.CardId, event.OwnerId)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !exists {
+			v.AddError("card_id", "card is not present in the table")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	err = app.models.Events.Move(event, target)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidAnchor) && target.After != 0:
+			v.AddError("after", "must be an event of the target card")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrInvalidAnchor):
+			v.AddError("before", "must be an event of the target card")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
 }
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/validation"
+	"net/http"
+	"net/url"
+)
+
+// listFeedsHandler возвращает календарные подписки пользователя, ?card_id= - только подписки на карточку
+func (app *Application) listFeedsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	v := validation.New()
+
+	cardId := app.readFeedCardId(r.URL.Query(), v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	feeds, err := app.models.Feeds.GetAll(user.ID, cardId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"feeds": feeds}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createFeedHandler создаёт подписку на все события или, если передан card_id,
+// на события карточки. Токен и адрес календаря возвращаются только в этом ответе
+func (app *Application) createFeedHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var input struct {
+		CardId *int64 `json:"card_id"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	if input.CardId != nil {
+		_, err = app.models.Cards.Get(*input.CardId, user.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v := validation.New()
+				v.AddError("card_id", "card is not present in the table")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	feed, err := app.models.Feeds.New(user.ID, input.CardId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	feedURL := fmt.Sprintf("/v1/feeds/%s/calendar.ics", feed.Token)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"feed": feed, "url": feedURL}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteFeedHandler отзывает подписку, после чего её адрес перестаёт работать
+func (app *Application) deleteFeedHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	err = app.models.Feeds.Delete(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "feed successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteFeedsHandler отзывает все подписки пользователя, ?card_id= - только подписки на карточку
+func (app *Application) deleteFeedsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	v := validation.New()
+
+	cardId := app.readFeedCardId(r.URL.Query(), v)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	revoked, err := app.models.Feeds.DeleteAll(user.ID, cardId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revoked": revoked}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// feedCalendarHandler отдаёт календарь подписки. Приложения календаря не передают
+// заголовок Authorization, поэтому пользователь определяется по токену в адресе.
+// Неизвестный, истёкший и отозванный токены, как и токен владельца без прав
+// на чтение событий, неотличимы от несуществующего адреса
+func (app *Application) feedCalendarHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	token := params.ByName("token")
+
+	v := validation.New()
+
+	if data.ValidateTokenPlainText(v, token); !v.Valid() {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	feed, err := app.models.Feeds.GetForToken(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Подписка действует, пока её владелец может читать события:
+	// деактивация или отзыв прав закрывает и выданные ранее адреса
+	user, err := app.models.Users.GetForToken(data.ScopeFeed, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	permissions, err := app.models.Permissions.GetForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !user.Activated || !permissions.Include("events:read") {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	qs := r.URL.Query()
+	name := "Events"
+
+	var filters data.EventFilters
+
+	if feed.CardId != nil {
+		card, err := app.models.Cards.Get(*feed.CardId, feed.UserId)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		name = card.Title
+		filters = app.readCardEventFilters(qs, card.ID, v)
+	} else {
+		filters = app.readEventFilters(qs, v)
+	}
+
+	if data.ValidateEventFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, err := app.models.Events.GetCalendar(feed.UserId, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTooManyEvents):
+			app.tooManyEventsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeCalendar(w, r, name, events)
+}
+
+// readFeedCardId читает необязательный параметр card_id, nil - параметр не передан
+func (app *Application) readFeedCardId(qs url.Values, v *validation.Validator) *int64 {
+	if qs.Get("card_id") == "" {
+		return nil
+	}
+
+	cardId := int64(app.readInt(qs, "card_id", 0, v))
+	v.Check(cardId > 0, "card_id", "must be a positive integer")
+
+	return &cardId
+}
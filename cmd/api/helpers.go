@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"io"
+	"library/internal/data"
 	"library/internal/validation"
 	"net/http"
 	"net/url"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +18,22 @@ import (
 
 type envelope map[string]interface{}
 
+// feedCalendarPath путь календаря подписки, токен в котором - долгоживущий секрет
+const feedCalendarPath = "/v1/feeds/*/calendar.ics"
+
+// redactURL возвращает адрес запроса для журнала, заменяя токен подписки
+func redactURL(u *url.URL) *url.URL {
+	if ok, _ := path.Match(feedCalendarPath, u.Path); !ok {
+		return u
+	}
+
+	redacted := *u
+	redacted.Path = "/v1/feeds/REDACTED/calendar.ics"
+	redacted.RawPath = ""
+
+	return &redacted
+}
+
 func (app *Application) readID(params httprouter.Params) (int64, error) {
 
 	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
@@ -129,6 +147,22 @@ func (app *Application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *Application) readBool(qs url.Values, key string, defaultValue bool, v *validation.Validator) bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return defaultValue
+	}
+
+	return b
+}
+
 func (app *Application) readDate(qs url.Values, key string, defaultValue time.Time, v *validation.Validator) time.Time {
 	s := qs.Get(key)
 
@@ -145,6 +179,67 @@ func (app *Application) readDate(qs url.Values, key string, defaultValue time.Ti
 	return t
 }
 
+// readIDs читает список идентификаторов, переданных через запятую
+func (app *Application) readIDs(qs url.Values, key string, v *validation.Validator) []int64 {
+	var ids []int64
+
+	for _, s := range app.readCSV(qs, key, nil) {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil || id < 1 {
+			v.AddError(key, "must be a comma-separated list of ids")
+			return nil
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// readCursor определяет режим постраничного вывода. Наличие параметра cursor
+// (в том числе пустого, для первой страницы) включает вывод по курсору
+func (app *Application) readCursor(qs url.Values, v *validation.Validator) (bool, *data.Cursor) {
+	if !qs.Has("cursor") {
+		return false, nil
+	}
+
+	s := qs.Get("cursor")
+	if s == "" {
+		return true, nil
+	}
+
+	cursor, err := data.DecodeCursor(s)
+	if err != nil {
+		v.AddError("cursor", "must be a cursor returned in next_cursor")
+		return true, nil
+	}
+
+	return true, cursor
+}
+
+// readDateRange читает фильтр по дате события. Поддерживаются:
+// date=YYYY-MM-DD - точная дата, date_from/date_to - диапазон включительно,
+// date=any или отсутствие параметров - без фильтрации по дате
+func (app *Application) readDateRange(qs url.Values, v *validation.Validator) (from, to data.Date) {
+	from.Time = app.readDate(qs, "date_from", time.Time{}, v)
+	to.Time = app.readDate(qs, "date_to", time.Time{}, v)
+
+	date := qs.Get("date")
+
+	if date == "" || date == "any" {
+		return from, to
+	}
+
+	if !from.IsZero() || !to.IsZero() {
+		v.AddError("date", "must not be combined with date_from or date_to")
+		return from, to
+	}
+
+	from.Time = app.readDate(qs, "date", time.Time{}, v)
+
+	return from, from
+}
+
 // isPreflight checks if the request is a preflight request.
 func isPreflight(r *http.Request) bool {
 	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
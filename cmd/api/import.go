@@ -0,0 +1,498 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"library/internal/data"
+	"library/internal/ical"
+	"library/internal/rrule"
+	"library/internal/validation"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCalendarImportSize ограничивает размер импортируемого файла
+const maxCalendarImportSize = 2 << 20
+
+// maxCalendarImportItems ограничивает количество событий и задач в одном импорте
+const maxCalendarImportItems = 500
+
+// Результаты импорта одного компонента
+const (
+	importCreated   = "created"
+	importUpdated   = "updated"
+	importUnchanged = "unchanged"
+	importFailed    = "failed"
+)
+
+// importResult результат импорта одного VEVENT или VTODO
+type importResult struct {
+	Index     int               `json:"index"`
+	Component string            `json:"component"`
+	UID       string            `json:"uid,omitempty"`
+	Action    string            `json:"action"`
+	Status    int               `json:"status"`
+	ID        int64             `json:"id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	// IgnoredExdates дни из EXDATE, на которые не приходится ни одно повторение события
+	IgnoredExdates []string `json:"ignored_exdates,omitempty"`
+}
+
+// importCalendarHandler импортирует события и задачи из файла iCalendar в карточку.
+// Файл передаётся в поле file формы multipart/form-data. Событие, уже импортированное
+// с тем же UID, обновляется и остаётся в своей карточке, если у пользователя есть право
+// events:update. Повторения серии из EXDATE отменяются. Компоненты, не прошедшие проверку,
+// пропускаются, остальные сохраняются
+func (app *Application) importCalendarHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	card, err := app.models.Cards.Get(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCalendarImportSize+multipartOverhead)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validation.New()
+
+	part, err := nextFilePart(mr, "file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.Is(err, io.EOF):
+			v.AddError("file", "must be provided")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.As(err, &maxBytesError):
+			app.fileTooLargeResponse(w, r, maxCalendarImportSize)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer part.Close()
+
+	cal, err := ical.Parse(part)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.fileTooLargeResponse(w, r, maxCalendarImportSize)
+		case errors.Is(err, ical.ErrInvalidCalendar):
+			v.AddError("file", err.Error())
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	var items []*ical.Component
+
+	if cal.Name == "VCALENDAR" {
+		for _, c := range cal.Components {
+			if c.Name == "VEVENT" || c.Name == "VTODO" {
+				items = append(items, c)
+			}
+		}
+	} else {
+		v.AddError("file", "must contain a VCALENDAR component")
+	}
+
+	v.Check(len(items) <= maxCalendarImportItems, "file",
+		fmt.Sprintf("must not contain more than %d events and to-dos", maxCalendarImportItems))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results := make([]*importResult, len(items))
+	counts := map[string]int{importCreated: 0, importUpdated: 0, importUnchanged: 0, importFailed: 0}
+
+	var updated []*data.Event
+
+	canUpdate := app.ctxGetPermissions(r).Include("events:update")
+
+	err = app.models.Events.Batch(func(b *data.EventBatch) (bool, error) {
+		for i, item := range items {
+			result := &importResult{Index: i, Component: item.Name, UID: strings.TrimSpace(item.Text("UID"))}
+			results[i] = result
+
+			var event *data.Event
+
+			err := b.Do(func() error {
+				var err error
+				event, err = importCalendarItem(b, item, card, user.ID, canUpdate, result)
+				return err
+			})
+
+			var itemErr *batchItemError
+
+			switch {
+			case errors.As(err, &itemErr):
+				result.Action = importFailed
+				result.Status = itemErr.status
+				result.Errors = itemErr.errors
+				result.Error = itemErr.message
+			case err != nil:
+				return false, err
+			case result.Action == importUpdated:
+				updated = append(updated, event)
+			}
+
+			counts[result.Action]++
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.rescheduleReminders(r, updated...)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"counts": counts, "results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// importCalendarItem создаёт или обновляет событие по компоненту item и заполняет result.
+// Ошибки клиента возвращаются как *batchItemError
+func importCalendarItem(b *data.EventBatch, item *ical.Component, card *data.Card, userId int64,
+	canUpdate bool, result *importResult) (*data.Event, error) {
+	v := validation.New()
+
+	// Изменённые повторения серии пришлось бы сопоставлять с исключениями серии
+	if item.Get("RECURRENCE-ID") != nil {
+		v.AddError("recurrence_id", "modified occurrences of recurring events are not supported")
+		return nil, batchValidationError(v)
+	}
+
+	if result.UID == "" {
+		v.AddError("uid", "must be provided")
+		return nil, batchValidationError(v)
+	}
+
+	if len(result.UID) > 255 {
+		v.AddError("uid", "must not be more than 255 bytes long")
+		return nil, batchValidationError(v)
+	}
+
+	imported, err := calendarItemEvent(item, v)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled, err := calendarItemCancelled(item, imported, v, result)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := b.GetByUID(result.UID, userId)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if existing == nil {
+		event := imported
+		event.CardId = card.ID
+		event.OwnerId = userId
+		event.ICalUID = result.UID
+
+		if data.ValidateEvent(v, event); !v.Valid() {
+			return nil, batchValidationError(v)
+		}
+
+		err = b.Insert(event)
+		if err != nil {
+			return nil, importModelError(err)
+		}
+
+		err = cancelOccurrences(b, event, cancelled)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Action = importCreated
+		result.Status = http.StatusCreated
+		result.ID = event.ID
+
+		return event, nil
+	}
+
+	result.ID = existing.ID
+
+	changed := applyCalendarItem(existing, imported)
+
+	// Отменяются только повторения, ещё не отменённые в прошлых импортах или через API
+	if len(cancelled) > 0 {
+		exceptions, err := b.Exceptions(existing.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		cancelled = slices.DeleteFunc(cancelled, func(d time.Time) bool {
+			return exceptions[d] != nil && exceptions[d].Cancelled
+		})
+	}
+
+	if !changed && len(cancelled) == 0 {
+		result.Action = importUnchanged
+		result.Status = http.StatusOK
+
+		return existing, nil
+	}
+
+	if !canUpdate {
+		return nil, &batchItemError{status: http.StatusForbidden,
+			message: "you do not have the necessary permissions to update this event"}
+	}
+
+	if changed {
+		if data.ValidateEvent(v, existing); !v.Valid() {
+			return nil, batchValidationError(v)
+		}
+
+		err = b.Update(existing)
+		if err != nil {
+			return nil, importModelError(err)
+		}
+	}
+
+	err = cancelOccurrences(b, existing, cancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Action = importUpdated
+	result.Status = http.StatusOK
+
+	return existing, nil
+}
+
+// calendarItemEvent переводит VEVENT или VTODO в событие без карточки и владельца
+func calendarItemEvent(item *ical.Component, v *validation.Validator) (*data.Event, error) {
+	event := &data.Event{
+		Title:       strings.TrimSpace(item.Text("SUMMARY")),
+		Description: strings.TrimSpace(item.Text("DESCRIPTION")),
+		Status:      calendarItemStatus(item),
+		Priority:    importPriority(item.Get("PRIORITY")),
+	}
+
+	// Каждая непустая строка описания становится абзацем, описание без текста - названием
+	for _, line := range strings.Split(event.Description, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			event.TextBlocks = append(event.TextBlocks, &data.Block{Type: data.BlockParagraph, Text: line})
+		}
+	}
+
+	if len(event.TextBlocks) == 0 && event.Title != "" {
+		event.TextBlocks = data.Blocks{{Type: data.BlockParagraph, Text: event.Title}}
+	}
+
+	// У задачи может не быть начала, тогда датой события становится срок
+	start := item.Get("DTSTART")
+	if start == nil && item.Name == "VTODO" {
+		start = item.Get("DUE")
+	}
+
+	if start != nil {
+		t, allDay, err := start.Time()
+		if err != nil {
+			v.AddError("date", err.Error())
+			return nil, batchValidationError(v)
+		}
+
+		event.Date = data.Date{Time: t, Timed: !allDay}
+	}
+
+	if p := item.Get("RRULE"); p != nil {
+		event.Recurrence = p.Value
+
+		// Правило приводится к виду, в котором его сохраняет API, например UNTIL - к дате
+		if rule, err := rrule.Parse(p.Value); err == nil {
+			event.Recurrence = rule.String()
+		}
+	}
+
+	return event, nil
+}
+
+// calendarItemCancelled возвращает дни повторений серии event, отменённых в EXDATE.
+// Время EXDATE переводится в часовой пояс серии, чтобы день совпал с днём повторения.
+// Дни, на которые не приходится ни одно повторение, добавляются в result.IgnoredExdates
+func calendarItemCancelled(item *ical.Component, event *data.Event, v *validation.Validator,
+	result *importResult) ([]time.Time, error) {
+	// Неверное правило отклонит ValidateEvent, до этого EXDATE не к чему применить
+	rule, _ := event.Rule()
+
+	var days []time.Time
+
+	for _, p := range item.All("EXDATE") {
+		times, _, err := p.Times()
+		if err != nil {
+			v.AddError("exdate", err.Error())
+			return nil, batchValidationError(v)
+		}
+
+		for _, t := range times {
+			if event.Date.Timed {
+				t = t.In(event.Date.Location())
+			}
+
+			day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+			if rule == nil || !rule.Includes(event.Date.Time, day) {
+				result.IgnoredExdates = append(result.IgnoredExdates, day.Format("2006-01-02"))
+				continue
+			}
+
+			if !slices.ContainsFunc(days, day.Equal) {
+				days = append(days, day)
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// cancelOccurrences отменяет повторения серии event в дни days
+func cancelOccurrences(b *data.EventBatch, event *data.Event, days []time.Time) error {
+	for _, d := range days {
+		err := b.SaveException(&data.EventException{EventId: event.ID, OccurrenceDate: d, Cancelled: true}, event.OwnerId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func calendarItemStatus(item *ical.Component) string {
+	status := strings.ToUpper(strings.TrimSpace(item.Text("STATUS")))
+
+	switch {
+	case status == "CANCELLED":
+		return data.StatusCancelled
+	case item.Name == "VTODO" && status == "COMPLETED":
+		return data.StatusDone
+	case item.Name == "VTODO" && status == "IN-PROCESS":
+		return data.StatusInProgress
+	default:
+		return data.StatusTodo
+	}
+}
+
+// importPriority переводит приоритет из шкалы RFC 5545, обратной к calendarPriority
+func importPriority(p *ical.Property) data.Priority {
+	if p == nil {
+		return data.PriorityNone
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(p.Value))
+	if err != nil {
+		return data.PriorityNone
+	}
+
+	switch {
+	case n >= 1 && n <= 2:
+		return data.PriorityUrgent
+	case n >= 3 && n <= 4:
+		return data.PriorityHigh
+	case n == 5:
+		return data.PriorityMedium
+	case n >= 6 && n <= 9:
+		return data.PriorityLow
+	default:
+		return data.PriorityNone
+	}
+}
+
+// applyCalendarItem переносит в event поля, пришедшие из календаря, и сообщает,
+// изменилось ли событие. Блоки текста заменяются, только если изменилось описание,
+// чтобы не потерять правки, сделанные после прошлого импорта
+func applyCalendarItem(event, imported *data.Event) bool {
+	changed := false
+
+	if event.Title != imported.Title {
+		event.Title = imported.Title
+		changed = true
+	}
+
+	if event.Description != imported.Description {
+		event.Description = imported.Description
+		event.TextBlocks = imported.TextBlocks
+		changed = true
+	}
+
+	if !sameDate(event.Date, imported.Date) {
+		event.Date = imported.Date
+		changed = true
+	}
+
+	if event.Status != imported.Status {
+		event.Status = imported.Status
+		changed = true
+	}
+
+	if event.Priority != imported.Priority {
+		event.Priority = imported.Priority
+		changed = true
+	}
+
+	if event.Recurrence != imported.Recurrence {
+		event.Recurrence = imported.Recurrence
+		changed = true
+	}
+
+	return changed
+}
+
+func sameDate(a, b data.Date) bool {
+	if a.Timed != b.Timed {
+		return false
+	}
+
+	if a.Timed {
+		return a.Equal(b.Time)
+	}
+
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
+
+// importModelError дополняет batchModelError ошибкой повторного UID
+func importModelError(err error) error {
+	if errors.Is(err, data.ErrDuplicateUID) {
+		v := validation.New()
+		v.AddError("uid", "event with this uid already exists")
+		return batchValidationError(v)
+	}
+
+	return batchModelError(err)
+}
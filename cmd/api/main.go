@@ -9,17 +9,19 @@ import (
 	"library/internal/logger"
 	"library/internal/mailer"
 	_ "library/internal/metrics"
+	"library/internal/storage"
 	"log/slog"
 	"sync"
 	"time"
 )
 
 type Application struct {
-	config config.Config
-	logger *slog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config  config.Config
+	logger  *slog.Logger
+	models  data.Models
+	mailer  mailer.Mailer
+	storage storage.Storage
+	wg      sync.WaitGroup
 }
 
 func main() {
@@ -38,11 +40,18 @@ func main() {
 
 	lgr.Info("database established")
 
+	files, err := storage.NewFS(cfg.Attachments.Dir)
+	if err != nil {
+		lgr.Error(err.Error())
+		return
+	}
+
 	app := &Application{
-		config: cfg,
-		logger: lgr,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.STMP.Host, cfg.STMP.Port, cfg.STMP.Username, cfg.STMP.Password, cfg.STMP.Sender),
+		config:  cfg,
+		logger:  lgr,
+		models:  data.NewModels(db),
+		mailer:  mailer.New(cfg.STMP.Host, cfg.STMP.Port, cfg.STMP.Username, cfg.STMP.Password, cfg.STMP.Sender),
+		storage: files,
 	}
 
 	err = app.Serve()
@@ -72,7 +72,7 @@ func (app *Application) logRequests(next http.Handler) http.Handler {
 		app.logger.Info("Log request information",
 			slog.String("address", realip.FromRequest(r)),
 			slog.String("method", r.Method),
-			slog.String("uri", r.URL.RequestURI()),
+			slog.String("uri", redactURL(r.URL).RequestURI()),
 			slog.String("protocol", r.Proto),
 		)
 
@@ -185,22 +185,14 @@ func (app *Application) requireAuthenticatedUser(next httprouter.Handle) httprou
 
 func (app *Application) requirePermission(permission string, next httprouter.Handle) httprouter.Handle {
 	fn := func(w http.ResponseWriter, r *http.Request, pm httprouter.Params) {
-		user := app.ctxGetUser(r)
-
-		perm, err := app.models.Permissions.GetForUser(user.ID)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-
-		if !perm.Include(permission) {
+		if !app.ctxGetPermissions(r).Include(permission) {
 			app.notPermittedResponse(w, r)
 			return
 		}
 
 		next(w, r, pm)
 	}
-	return app.requireAuthenticatedUser(fn)
+	return app.requireActivatedUser(fn)
 }
 
 func (app *Application) authenticate(next http.Handler) http.Handler {
@@ -240,7 +232,16 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// Права загружаются один раз на запрос: их проверяет requirePermission,
+		// а пакетные обработчики - каждую операцию отдельно
+		perm, err := app.models.Permissions.GetForUser(user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
 		r = app.ctxSetUser(r, user)
+		r = app.ctxSetPermissions(r, perm)
 
 		next.ServeHTTP(w, r)
 	})
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/validation"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (app *Application) listRemindersHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return
+	}
+
+	reminders, err := app.models.Reminders.GetForEvent(event.ID, event.OwnerId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"reminders": reminders}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) createReminderHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		MinutesBefore *int    `json:"minutes_before"`
+		At            *string `json:"at"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	reminder := &data.Reminder{
+		EventId:       event.ID,
+		OwnerId:       event.OwnerId,
+		MinutesBefore: input.MinutesBefore,
+		At:            input.At,
+	}
+
+	v := validation.New()
+
+	if data.ValidateReminder(v, reminder); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !reminder.Schedule(event, time.Now()) {
+		v.AddError("reminder", "reminder time has already passed")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Reminders.Insert(reminder)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTooManyReminders):
+			v.AddError("reminder", "event has too many reminders")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/events/%d/reminders", event.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"reminder": reminder}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteReminderHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	eventId, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := strconv.ParseInt(params.ByName("reminder_id"), 10, 64)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	err = app.models.Reminders.Delete(id, eventId, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "reminder deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readEvent читает событие текущего пользователя по id из пути.
+// Если события нет, ответ уже отправлен и возвращается false
+func (app *Application) readEvent(w http.ResponseWriter, r *http.Request, params httprouter.Params) (*data.Event, bool) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	user := app.ctxGetUser(r)
+
+	event, err := app.models.Events.Get(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return event, true
+}
+
+// rescheduleReminders пересчитывает напоминания сохранённых событий. Изменения уже
+// сохранены, поэтому ошибка пересчёта только записывается в журнал и не влияет на ответ
+func (app *Application) rescheduleReminders(r *http.Request, events ...*data.Event) {
+	for _, event := range events {
+		err := app.models.Reminders.Reschedule(event)
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+}
+
+// runReminders периодически отправляет напоминания, время которых наступило,
+// пока не будет отменён ctx. Неотправленные напоминания хранятся в базе,
+// поэтому после перезапуска отправка продолжается с того же места
+func (app *Application) runReminders(ctx context.Context) {
+	ticker := time.NewTicker(app.config.Reminders.Interval)
+	defer ticker.Stop()
+
+	app.logger.Info("reminder scheduler started",
+		slog.Duration("interval", app.config.Reminders.Interval),
+	)
+
+	for {
+		app.sendDueReminders(ctx)
+
+		select {
+		case <-ctx.Done():
+			app.logger.Info("reminder scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *Application) sendDueReminders(ctx context.Context) {
+	due, err := app.models.Reminders.ClaimDue(app.config.Reminders.Batch)
+	if err != nil {
+		app.logger.Error(err.Error())
+		return
+	}
+
+	for _, reminder := range due {
+		// Оставшиеся напоминания будут выданы снова после истечения срока закрепления
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := app.sendReminder(reminder)
+		if err != nil {
+			app.logger.Error(err.Error(), slog.Int64("reminder_id", reminder.ID))
+
+			if err := app.models.Reminders.Fail(&reminder.Reminder, err); err != nil {
+				app.logger.Error(err.Error())
+			}
+		}
+	}
+}
+
+// sendReminder отправляет письмо с напоминанием и отмечает его отправленным.
+// Напоминания об удалённых, завершённых или отменённых повторениях не отправляются
+func (app *Application) sendReminder(reminder *data.DueReminder) error {
+	event, err := app.models.Events.Get(reminder.EventId, reminder.OwnerId)
+	if err != nil {
+		return err
+	}
+
+	occurrence := event
+	if reminder.OccurrenceDate != nil {
+		occurrence, err = app.models.Events.Occurrence(event, reminder.OccurrenceDate.Time)
+		if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	if occurrence != nil && occurrence.Status != data.StatusDone && occurrence.Status != data.StatusCancelled {
+		date := occurrence.Date.Format("02.01.2006")
+		if occurrence.Date.Timed {
+			date = occurrence.Date.Format("02.01.2006 15:04 MST")
+		}
+
+		emailData := map[string]interface{}{
+			"name":        reminder.Name,
+			"title":       occurrence.Title,
+			"description": occurrence.Description,
+			"date":        date,
+		}
+
+		err = app.mailer.Send(reminder.Email, "reminder.gohtml", emailData)
+		if err != nil {
+			return err
+		}
+	}
+
+	return app.models.Reminders.Complete(&reminder.Reminder, event)
+}
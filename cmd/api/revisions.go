@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/validation"
+	"net/http"
+	"strconv"
+)
+
+func (app *Application) listRevisionsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return
+	}
+
+	var input data.Filters
+
+	v := validation.New()
+
+	qs := r.URL.Query()
+
+	input.Sort = app.readString(qs, "sort", "-version")
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.SortSafeList = []string{"version", "-version"}
+
+	if data.ValidateFilters(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	revisions, metadata, err := app.models.Revisions.GetAll(event.ID, input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "revisions": revisions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) showRevisionHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	_, revision, ok := app.readRevision(w, r, params)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"revision": revision}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// diffRevisionsHandler сравнивает версию с версией из параметра from, по умолчанию с предыдущей
+func (app *Application) diffRevisionsHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	event, to, ok := app.readRevision(w, r, params)
+	if !ok {
+		return
+	}
+
+	v := validation.New()
+
+	from := int64(app.readInt(r.URL.Query(), "from", int(to.Version)-1, v))
+
+	v.Check(from >= 1, "from", "must be greater than or equal to 1")
+	v.Check(from != to.Version, "from", "must differ from the compared version")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	fromRevision, err := app.models.Revisions.Get(event.ID, from)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	changes, err := data.Diff(fromRevision, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"from": from, "to": to.Version, "changes": changes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// restoreRevisionHandler возвращает событию состояние версии, сохраняя его как новую версию.
+// Текущую версию события можно передать в параметре version
+func (app *Application) restoreRevisionHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	v := validation.New()
+
+	version := app.readVersion(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	event, revision, ok := app.readRevision(w, r, params)
+	if !ok {
+		return
+	}
+
+	if version != nil && *version != event.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+
+	cardId := event.CardId
+
+	revision.State.ApplyTo(event)
+
+	if data.ValidateEvent(v, event); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if event.CardId != cardId {
+		exists, err := app.models.Cards.Exists(event.CardId, event.OwnerId)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !exists {
+			v.AddError("card_id", "card of this revision no longer exists")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	err := app.models.Events.Update(event)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateTitle):
+			v.AddError("title", "event with this title already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.rescheduleReminders(r, event)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readRevision читает событие пользователя и его версию из параметров пути.
+// Если их нет, ответ уже отправлен и возвращается false
+func (app *Application) readRevision(w http.ResponseWriter, r *http.Request, params httprouter.Params) (*data.Event, *data.Revision, bool) {
+	event, ok := app.readEvent(w, r, params)
+	if !ok {
+		return nil, nil, false
+	}
+
+	version, err := strconv.ParseInt(params.ByName("version"), 10, 64)
+	if err != nil || version < 1 {
+		app.notFoundResponse(w, r)
+		return nil, nil, false
+	}
+
+	revision, err := app.models.Revisions.Get(event.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, nil, false
+	}
+
+	return event, revision, true
+}
@@ -18,15 +18,78 @@ func (app *Application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/v1/metrics", promhttp.Handler())
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 
-	router.GET("/v1/events", app.requireActivatedUser(app.listEventHandler))
-	router.GET("/v1/events/:id", app.requireActivatedUser(app.showEventHandler))
-	router.POST("/v1/events", app.requireActivatedUser(app.createEventHandler))
-	router.PATCH("/v1/events/:id", app.requireActivatedUser(app.updateEventHandler))
-	router.DELETE("/v1/events/:id", app.requireActivatedUser(app.deleteEventHandler))
-
-	router.GET("/v1/cards/:id", app.requireActivatedUser(app.showCardHandler))
-	router.POST("/v1/cards", app.requireActivatedUser(app.createCardHandler))
-	router.PATCH("/v1/cards/:id", app.requireActivatedUser(app.updateCardHandler))
+	router.GET("/v1/events", app.requirePermission("events:read", app.listEventHandler))
+	router.GET("/v1/events.ics", app.requirePermission("events:read", app.exportEventsHandler))
+	router.GET("/v1/events/:id", app.requirePermission("events:read", app.showEventHandler))
+	router.POST("/v1/events", app.requirePermission("events:create", app.createEventHandler))
+	router.PATCH("/v1/events/:id", app.requirePermission("events:update", app.updateEventHandler))
+	router.DELETE("/v1/events/:id", app.requirePermission("events:delete", app.deleteEventHandler))
+	router.POST("/v1/events/:id/complete", app.requirePermission("events:update", app.completeEventHandler))
+	router.POST("/v1/events/:id/reopen", app.requirePermission("events:update", app.reopenEventHandler))
+	router.POST("/v1/events/:id/move", app.requirePermission("events:update", app.moveEventHandler))
+	router.POST("/v1/events/:id/restore", app.requirePermission("events:update", app.restoreEventHandler))
+	router.POST("/v1/events/:id/blocks", app.requirePermission("events:update", app.appendBlockHandler))
+	router.PUT("/v1/events/:id/blocks", app.requirePermission("events:update", app.reorderBlocksHandler))
+	router.PATCH("/v1/events/:id/blocks/:block_id", app.requirePermission("events:update", app.updateBlockHandler))
+	router.DELETE("/v1/events/:id/blocks/:block_id", app.requirePermission("events:update", app.deleteBlockHandler))
+	router.GET("/v1/events/:id/reminders", app.requirePermission("events:read", app.listRemindersHandler))
+	router.POST("/v1/events/:id/reminders", app.requirePermission("events:update", app.createReminderHandler))
+	router.DELETE("/v1/events/:id/reminders/:reminder_id", app.requirePermission("events:update", app.deleteReminderHandler))
+	router.GET("/v1/events/:id/revisions", app.requirePermission("events:read", app.listRevisionsHandler))
+	router.GET("/v1/events/:id/revisions/:version", app.requirePermission("events:read", app.showRevisionHandler))
+	router.GET("/v1/events/:id/revisions/:version/diff", app.requirePermission("events:read", app.diffRevisionsHandler))
+	router.POST("/v1/events/:id/revisions/:version/restore", app.requirePermission("events:update", app.restoreRevisionHandler))
+	router.GET("/v1/events/:id/comments", app.requirePermission("events:read", app.listCommentsHandler))
+	router.POST("/v1/events/:id/comments", app.requirePermission("events:update", app.createCommentHandler))
+	router.PATCH("/v1/events/:id/comments/:comment_id", app.requirePermission("events:update", app.updateCommentHandler))
+	router.DELETE("/v1/events/:id/comments/:comment_id", app.requirePermission("events:update", app.deleteCommentHandler))
+	router.GET("/v1/events/:id/attachments", app.requirePermission("events:read", app.listAttachmentsHandler))
+	router.POST("/v1/events/:id/attachments", app.requirePermission("events:update", app.uploadAttachmentHandler))
+	router.GET("/v1/events/:id/attachments/:attachment_id", app.requirePermission("events:read", app.downloadAttachmentHandler))
+	router.DELETE("/v1/events/:id/attachments/:attachment_id", app.requirePermission("events:update", app.deleteAttachmentHandler))
+
+	router.GET("/v1/cards", app.requirePermission("cards:read", app.listCardHandler))
+	router.GET("/v1/cards/:id", app.requirePermission("cards:read", app.showCardHandler))
+	router.GET("/v1/cards/:id/events", app.requirePermission("events:read", app.listCardEventsHandler))
+	router.GET("/v1/cards/:id/events.ics", app.requirePermission("events:read", app.exportCardEventsHandler))
+	router.POST("/v1/cards/:id/import/ics", app.requirePermission("events:create", app.importCalendarHandler))
+	router.POST("/v1/cards", app.requirePermission("cards:create", app.createCardHandler))
+	router.PATCH("/v1/cards/:id", app.requirePermission("cards:update", app.updateCardHandler))
+	router.DELETE("/v1/cards/:id", app.requirePermission("cards:delete", app.deleteCardHandler))
+
+	// Права на create, update и delete batchEventsHandler проверяет для каждой операции.
+	// Путь /v1/events/batch конфликтует в httprouter с /v1/events/:id
+	router.POST("/v1/batch/events", app.requireActivatedUser(app.batchEventsHandler))
+
+	router.GET("/v1/feeds", app.requirePermission("events:read", app.listFeedsHandler))
+	router.POST("/v1/feeds", app.requirePermission("events:update", app.createFeedHandler))
+	router.DELETE("/v1/feeds", app.requirePermission("events:update", app.deleteFeedsHandler))
+	router.DELETE("/v1/feeds/:id", app.requirePermission("events:update", app.deleteFeedHandler))
+	// Подписка открывается по токену в адресе, без заголовка Authorization
+	router.GET("/v1/feeds/:token/calendar.ics", app.feedCalendarHandler)
+
+	router.GET("/v1/tags", app.requirePermission("events:read", app.listTagsHandler))
+	router.POST("/v1/tags", app.requirePermission("events:create", app.createTagHandler))
+	router.PATCH("/v1/tags/:id", app.requirePermission("events:update", app.updateTagHandler))
+	router.DELETE("/v1/tags/:id", app.requirePermission("events:delete", app.deleteTagHandler))
+
+	router.GET("/v1/trash", app.requirePermission("events:read", app.listTrashHandler))
+	router.DELETE("/v1/trash", app.requirePermission("events:delete", app.emptyTrashHandler))
+	router.DELETE("/v1/trash/:id", app.requirePermission("events:delete", app.purgeEventHandler))
+
+	router.GET("/v1/admin/permissions", app.requirePermission("admin:access", app.listPermissionsHandler))
+	router.GET("/v1/admin/roles", app.requirePermission("admin:access", app.listRolesHandler))
+	router.POST("/v1/admin/roles", app.requirePermission("admin:access", app.createRoleHandler))
+	router.DELETE("/v1/admin/roles/:id", app.requirePermission("admin:access", app.deleteRoleHandler))
+	router.GET("/v1/admin/roles/:id/permissions", app.requirePermission("admin:access", app.showRolePermissionsHandler))
+	router.POST("/v1/admin/roles/:id/permissions", app.requirePermission("admin:access", app.grantRolePermissionsHandler))
+	router.DELETE("/v1/admin/roles/:id/permissions", app.requirePermission("admin:access", app.revokeRolePermissionsHandler))
+	router.GET("/v1/admin/users/:id/roles", app.requirePermission("admin:access", app.listUserRolesHandler))
+	router.POST("/v1/admin/users/:id/roles", app.requirePermission("admin:access", app.grantUserRolesHandler))
+	router.DELETE("/v1/admin/users/:id/roles", app.requirePermission("admin:access", app.revokeUserRolesHandler))
+	router.GET("/v1/admin/users/:id/permissions", app.requirePermission("admin:access", app.listUserPermissionsHandler))
+	router.POST("/v1/admin/users/:id/permissions", app.requirePermission("admin:access", app.grantUserPermissionsHandler))
+	router.DELETE("/v1/admin/users/:id/permissions", app.requirePermission("admin:access", app.revokeUserPermissionsHandler))
 
 	router.POST("/v1/users", app.registerHandler)
 	router.PUT("/v1/users/activated", app.activateUserHandle)
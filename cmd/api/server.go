@@ -25,6 +25,22 @@ func (app *Application) Serve() error {
 
 	shutdownError := make(chan error)
 
+	// ctx отменяется при остановке сервера и завершает фоновые задачи
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	if app.config.Reminders.Enabled {
+		app.background(func() {
+			app.runReminders(ctx)
+		})
+	}
+
+	if app.config.Trash.PurgeInterval > 0 {
+		app.background(func() {
+			app.runTrashPurge(ctx)
+		})
+	}
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
@@ -36,10 +52,12 @@ func (app *Application) Serve() error {
 			slog.String("signal", s.String()),
 		)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		stop()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		err := srv.Shutdown(ctx)
+		err := srv.Shutdown(shutdownCtx)
 		if err != nil {
 			shutdownError <- err
 		}
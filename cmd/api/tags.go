@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/validation"
+	"net/http"
+)
+
+// defaultTagColor цвет метки, если он не указан при создании
+const defaultTagColor = "#808080"
+
+func (app *Application) listTagsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	user := app.ctxGetUser(r)
+
+	tags, err := app.models.Tags.GetAll(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) createTagHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var input struct {
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	tag := &data.Tag{
+		Name:    input.Name,
+		Color:   input.Color,
+		OwnerId: user.ID,
+	}
+
+	if tag.Color == "" {
+		tag.Color = defaultTagColor
+	}
+
+	v := validation.New()
+
+	if data.ValidateTag(v, tag); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Tags.Insert(tag)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTag):
+			v.AddError("name", "tag with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/tags/%d", tag.ID))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"tag": tag}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) updateTagHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	tag, err := app.models.Tags.Get(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name  *string `json:"name"`
+		Color *string `json:"color"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		tag.Name = *input.Name
+	}
+
+	if input.Color != nil {
+		tag.Color = *input.Color
+	}
+
+	v := validation.New()
+
+	if data.ValidateTag(v, tag); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Tags.Update(tag)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateTag):
+			v.AddError("name", "tag with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tag": tag}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *Application) deleteTagHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	err = app.models.Tags.Delete(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "tag deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
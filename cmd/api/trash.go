@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"library/internal/data"
+	"library/internal/validation"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// listTrashHandler возвращает события пользователя в корзине, по умолчанию недавно удалённые первыми
+func (app *Application) listTrashHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var input data.Filters
+
+	v := validation.New()
+
+	qs := r.URL.Query()
+
+	input.Sort = app.readString(qs, "sort", "-deleted_at")
+	input.Page = app.readInt(qs, "page", 1, v)
+	input.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.SortSafeList = []string{"deleted_at", "title", "date", "-deleted_at", "-title", "-date"}
+
+	if data.ValidateFilters(v, input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	events, metadata, err := app.models.Events.GetTrash(user.ID, input)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "events": events}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// restoreEventHandler возвращает событие из корзины
+func (app *Application) restoreEventHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	event, err := app.models.Events.Restore(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateTitle):
+			v := validation.New()
+			v.AddError("title", "event with this title already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrDuplicateUID):
+			v := validation.New()
+			v.AddError("ical_uid", "event with this calendar uid was imported again")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Пока событие было в корзине, время его напоминаний могло пройти
+	app.rescheduleReminders(r, event)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"event": event}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeEventHandler окончательно удаляет событие из корзины
+func (app *Application) purgeEventHandler(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	id, err := app.readID(params)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.ctxGetUser(r)
+
+	err = app.models.Events.Purge(id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "event deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// emptyTrashHandler окончательно удаляет все события пользователя из корзины
+func (app *Application) emptyTrashHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	user := app.ctxGetUser(r)
+
+	deleted, err := app.models.Events.EmptyTrash(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"deleted": deleted}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// runTrashPurge периодически удаляет события, пролежавшие в корзине дольше
+// config.Trash.Retention, и файлы окончательно удалённых событий, пока не будет отменён ctx
+func (app *Application) runTrashPurge(ctx context.Context) {
+	ticker := time.NewTicker(app.config.Trash.PurgeInterval)
+	defer ticker.Stop()
+
+	app.logger.Info("trash purge started",
+		slog.Duration("retention", app.config.Trash.Retention),
+		slog.Duration("interval", app.config.Trash.PurgeInterval),
+	)
+
+	for {
+		if app.config.Trash.Retention > 0 {
+			deleted, err := app.models.Events.PurgeTrash(app.config.Trash.Retention)
+			if err != nil {
+				app.logger.Error(err.Error())
+			} else if deleted > 0 {
+				app.logger.Info("purged trash", slog.Int64("events", deleted))
+			}
+		}
+
+		app.deleteOrphanedAttachments(ctx)
+
+		select {
+		case <-ctx.Done():
+			app.logger.Info("trash purge stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"library/internal/metrics"
 	"os"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -32,6 +33,22 @@ type Config struct {
 	CORS struct {
 		AllowedOrigins []string
 	}
+	Search struct {
+		Language string
+	}
+	Reminders struct {
+		Enabled  bool
+		Interval time.Duration
+		Batch    int
+	}
+	Trash struct {
+		Retention     time.Duration
+		PurgeInterval time.Duration
+	}
+	Attachments struct {
+		Dir     string
+		MaxSize int64
+	}
 }
 
 func (cfg *Config) SetEnvironment() {
@@ -55,6 +72,18 @@ func (cfg *Config) SetEnvironment() {
 	flag.StringVar(&cfg.STMP.Password, "smtp-password", "e656e78aaac168", "STMP password")
 	flag.StringVar(&cfg.STMP.Sender, "smtp-sender", "Todo <no-reply@todo.goserv.ru>", "SMTP sender")
 
+	flag.StringVar(&cfg.Search.Language, "search-language", "english", "Default full-text search dictionary(english|russian)")
+
+	flag.BoolVar(&cfg.Reminders.Enabled, "reminders-enabled", true, "Enable sending event reminders")
+	flag.DurationVar(&cfg.Reminders.Interval, "reminders-interval", 30*time.Second, "Interval between checks for due reminders")
+	flag.IntVar(&cfg.Reminders.Batch, "reminders-batch", 50, "Maximum reminders sent per check")
+
+	flag.DurationVar(&cfg.Trash.Retention, "trash-retention", 30*24*time.Hour, "How long deleted events are kept in trash(0 disables purging)")
+	flag.DurationVar(&cfg.Trash.PurgeInterval, "trash-purge-interval", time.Hour, "Interval between trash purges")
+
+	flag.StringVar(&cfg.Attachments.Dir, "attachments-dir", "./uploads", "Directory for event attachments")
+	flag.Int64Var(&cfg.Attachments.MaxSize, "attachments-max-size", 10<<20, "Maximum attachment size in bytes")
+
 	flag.Func("cors-allowed-origins", "Comma-separated list of allowed CORS origins", func(s string) error {
 		cfg.CORS.AllowedOrigins = strings.Fields(s)
 		return nil
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"library/internal/validation"
+	"time"
+	"unicode/utf8"
+)
+
+// Attachment файл, прикреплённый к событию. Содержимое хранится
+// в storage.Storage под ключом StorageKey
+type Attachment struct {
+	ID          int64     `json:"id"`
+	EventId     int64     `json:"event_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	StorageKey  string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewAttachmentKey возвращает новый ключ хранилища для файла события
+func NewAttachmentKey(eventId int64) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return fmt.Sprintf("events/%d/%s", eventId, hex.EncodeToString(b))
+}
+
+func ValidateAttachment(v *validation.Validator, a *Attachment) {
+	v.Check(a.Filename != "", "file", "filename must be provided")
+	v.Check(len(a.Filename) <= 255, "file", "filename must not be more than 255 bytes long")
+	v.Check(utf8.ValidString(a.Filename), "file", "filename must be valid utf-8")
+}
+
+type AttachmentModel struct {
+	DB *sql.DB
+}
+
+const attachmentColumns = `id, coalesce(event_id, 0), filename, content_type, size, storage_key, created_at`
+
+func (a *Attachment) columns() []interface{} {
+	return []interface{}{
+		&a.ID,
+		&a.EventId,
+		&a.Filename,
+		&a.ContentType,
+		&a.Size,
+		&a.StorageKey,
+		&a.CreatedAt,
+	}
+}
+
+func (m AttachmentModel) GetAll(eventId int64) ([]*Attachment, error) {
+	q := `select ` + attachmentColumns + `
+		from attachments
+		where event_id = $1
+		order by created_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.query(ctx, q, eventId)
+}
+
+func (m AttachmentModel) Get(id, eventId int64) (*Attachment, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	q := `select ` + attachmentColumns + `
+		from attachments
+		where id = $1 and event_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var a Attachment
+
+	err := m.DB.QueryRowContext(ctx, q, id, eventId).Scan(a.columns()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &a, nil
+}
+
+// Insert сохраняет сведения о файле, содержимое которого уже записано в хранилище.
+// Возвращает ErrRecordNotFound, если событие успели удалить
+func (m AttachmentModel) Insert(a *Attachment) error {
+	q := `insert into attachments (event_id, filename, content_type, size, storage_key)
+		select $1, $2, $3, $4, $5
+		where exists (select 1 from events where id = $1 and deleted_at is null)
+		returning id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{a.EventId, a.Filename, a.ContentType, a.Size, a.StorageKey}
+
+	err := m.DB.QueryRowContext(ctx, q, args...).Scan(&a.ID, &a.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete удаляет сведения о файле события, содержимое из хранилища удаляет вызывающий
+func (m AttachmentModel) Delete(id, eventId int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	q := `delete from attachments where id = $1 and event_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, q, id, eventId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetOrphaned возвращает до limit файлов, события которых удалены окончательно
+func (m AttachmentModel) GetOrphaned(limit int) ([]*Attachment, error) {
+	q := `select ` + attachmentColumns + `
+		from attachments
+		where event_id is null
+		order by id
+		limit $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.query(ctx, q, limit)
+}
+
+// DeleteOrphaned удаляет сведения о файле, содержимое которого уже удалено из хранилища
+func (m AttachmentModel) DeleteOrphaned(id int64) error {
+	q := `delete from attachments where id = $1 and event_id is null`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, q, id)
+	return err
+}
+
+func (m AttachmentModel) query(ctx context.Context, q string, args ...interface{}) ([]*Attachment, error) {
+	rows, err := m.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []*Attachment{}
+
+	for rows.Next() {
+		var a Attachment
+
+		err := rows.Scan(a.columns()...)
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, &a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
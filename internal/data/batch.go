@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxBatchOperations ограничивает количество операций в одном пакете
+const MaxBatchOperations = 100
+
+// Режимы выполнения пакета операций
+const (
+	// BatchAtomic применяет пакет, только если успешны все операции
+	BatchAtomic = "atomic"
+	// BatchBestEffort применяет успешные операции, пропуская неудачные
+	BatchBestEffort = "best_effort"
+)
+
+// EventBatch операции над событиями внутри одной транзакции. Каждая операция,
+// выполненная через Do, изолирована точкой сохранения, поэтому её ошибка
+// не прерывает транзакцию
+type EventBatch struct {
+	ctx context.Context
+	tx  *sql.Tx
+	n   int
+}
+
+// Batch выполняет fn в транзакции. Если fn возвращает ошибку или commit == false,
+// транзакция откатывается
+func (e EventModel) Batch(fn func(b *EventBatch) (commit bool, err error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	commit, err := fn(&EventBatch{ctx: ctx, tx: tx})
+	if err != nil || !commit {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Do выполняет одну операцию пакета. При ошибке изменения, сделанные
+// операцией, отменяются, а ошибка возвращается вызывающему
+func (b *EventBatch) Do(fn func() error) error {
+	b.n++
+	savepoint := fmt.Sprintf("batch_op_%d", b.n)
+
+	_, err := b.tx.ExecContext(b.ctx, "savepoint "+savepoint)
+	if err != nil {
+		return err
+	}
+
+	opErr := fn()
+	if opErr != nil {
+		_, err = b.tx.ExecContext(b.ctx, "rollback to savepoint "+savepoint)
+		if err != nil {
+			return err
+		}
+
+		return opErr
+	}
+
+	_, err = b.tx.ExecContext(b.ctx, "release savepoint "+savepoint)
+
+	return err
+}
+
+func (b *EventBatch) Get(id, ownerId int64) (*Event, error) {
+	return getEvent(b.ctx, b.tx, id, ownerId)
+}
+
+func (b *EventBatch) Insert(event *Event) error {
+	return insertEvent(b.ctx, b.tx, event)
+}
+
+func (b *EventBatch) Update(event *Event) error {
+	return updateEvent(b.ctx, b.tx, event)
+}
+
+func (b *EventBatch) Delete(id, ownerId int64) error {
+	return deleteEvent(b.ctx, b.tx, id, ownerId)
+}
+
+func (b *EventBatch) CardExists(id, ownerId int64) (bool, error) {
+	return cardExists(b.ctx, b.tx, id, ownerId)
+}
+
+// SetTags заменяет метки события, ErrTagNotFound - если какой-то метки у пользователя нет
+func (b *EventBatch) SetTags(event *Event, ids []int64) error {
+	return setEventTags(b.ctx, b.tx, event, ids)
+}
+
+// SaveException сохраняет изменения одного повторения события, как EventModel.SaveException
+func (b *EventBatch) SaveException(ex *EventException, ownerId int64) error {
+	return saveException(b.ctx, b.tx, ex, ownerId)
+}
+
+// Exceptions возвращает изменения всех повторений события id по дням
+func (b *EventBatch) Exceptions(id int64) (map[time.Time]*EventException, error) {
+	to := time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	exceptions, err := getExceptions(b.ctx, b.tx, []int64{id}, time.Time{}, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return exceptions[id], nil
+}
+
+// GetByUID возвращает событие, импортированное из календаря с UID uid
+func (b *EventBatch) GetByUID(uid string, ownerId int64) (*Event, error) {
+	if uid == "" {
+		return nil, ErrRecordNotFound
+	}
+
+	q := `select ` + eventColumns + `
+		from events
+		where ical_uid = $1 and owner_id = $2 and deleted_at is null`
+
+	var event Event
+
+	err := b.tx.QueryRowContext(b.ctx, q, uid, ownerId).Scan(event.columns()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	event.prevStatus = event.Status
+
+	return &event, nil
+}
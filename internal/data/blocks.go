@@ -0,0 +1,211 @@
+package data
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"library/internal/validation"
+	"net/url"
+)
+
+// Типы блоков текста события
+const (
+	BlockParagraph = "paragraph"
+	BlockChecklist = "checklist"
+	BlockHeading   = "heading"
+	BlockCode      = "code"
+	BlockLink      = "link"
+)
+
+var BlockTypes = []string{BlockParagraph, BlockChecklist, BlockHeading, BlockCode, BlockLink}
+
+// maxTextBlocks совпадает с ограничением events_text_blocks_check
+const maxTextBlocks = 50
+
+// Block блок текста события. Checked задаётся только для пунктов списка,
+// Level - для заголовков, Language - для кода, URL - для ссылок
+type Block struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Checked  *bool  `json:"checked,omitempty"`
+	Level    int    `json:"level,omitempty"`
+	Language string `json:"language,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// UnmarshalJSON кроме объекта принимает строку, которая становится абзацем.
+// Так продолжают работать клиенты, передающие text_blocks списком строк
+func (b *Block) UnmarshalJSON(js []byte) error {
+	if len(js) > 0 && js[0] == '"' {
+		b.Type = BlockParagraph
+		return json.Unmarshal(js, &b.Text)
+	}
+
+	type block Block
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+
+	return dec.Decode((*block)(b))
+}
+
+// Blocks блоки текста события, хранятся в столбце text_blocks типа jsonb
+type Blocks []*Block
+
+func (b *Blocks) Scan(src any) error {
+	var js []byte
+
+	switch v := src.(type) {
+	case nil:
+		*b = Blocks{}
+		return nil
+	case []byte:
+		js = v
+	case string:
+		js = []byte(v)
+	default:
+		return fmt.Errorf("unsupported text_blocks type %T", src)
+	}
+
+	*b = Blocks{}
+
+	return json.Unmarshal(js, b)
+}
+
+func (b Blocks) Value() (driver.Value, error) {
+	if b == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(b)
+}
+
+// normalize выдаёт идентификаторы блокам, у которых их ещё нет,
+// и явно снимает отметку с пунктов списка без checked
+func (b Blocks) normalize() {
+	for _, block := range b {
+		if block.ID == "" {
+			block.ID = newBlockID()
+		}
+
+		if block.Type == BlockChecklist && block.Checked == nil {
+			checked := false
+			block.Checked = &checked
+		}
+	}
+}
+
+func newBlockID() string {
+	id := make([]byte, 6)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// Index возвращает позицию блока с идентификатором id или -1
+func (b Blocks) Index(id string) int {
+	for i, block := range b {
+		if block.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Insert вставляет блок в позицию position, отрицательная или слишком
+// большая позиция означает конец списка
+func (b Blocks) Insert(block *Block, position int) Blocks {
+	if position < 0 || position > len(b) {
+		position = len(b)
+	}
+
+	blocks := make(Blocks, 0, len(b)+1)
+	blocks = append(blocks, b[:position]...)
+	blocks = append(blocks, block)
+
+	return append(blocks, b[position:]...)
+}
+
+// Remove возвращает блоки без блока в позиции i
+func (b Blocks) Remove(i int) Blocks {
+	blocks := make(Blocks, 0, len(b))
+	blocks = append(blocks, b[:i]...)
+
+	return append(blocks, b[i+1:]...)
+}
+
+// ChecklistProgress количество отмеченных пунктов списка из общего числа
+type ChecklistProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Progress возвращает прогресс по пунктам списка или nil, если их нет
+func (b Blocks) Progress() *ChecklistProgress {
+	var p ChecklistProgress
+
+	for _, block := range b {
+		if block.Type != BlockChecklist {
+			continue
+		}
+
+		p.Total++
+		if block.Checked != nil && *block.Checked {
+			p.Done++
+		}
+	}
+
+	if p.Total == 0 {
+		return nil
+	}
+
+	return &p
+}
+
+func ValidateBlocks(v *validation.Validator, blocks Blocks) {
+	v.Check(blocks != nil, "text_blocks", "must be provided")
+	v.Check(len(blocks) >= 1, "text_blocks", "must contain at least 1 element")
+	v.Check(len(blocks) <= maxTextBlocks, "text_blocks", fmt.Sprintf("must not contain more than %d elements", maxTextBlocks))
+
+	ids := make(map[string]bool, len(blocks))
+
+	for _, block := range blocks {
+		if block == nil {
+			v.AddError("text_blocks", "must not contain empty elements")
+			continue
+		}
+
+		ValidateBlock(v, block)
+
+		if block.ID != "" {
+			v.Check(!ids[block.ID], "text_blocks", "must not contain duplicate block ids")
+			ids[block.ID] = true
+		}
+	}
+}
+
+func ValidateBlock(v *validation.Validator, block *Block) {
+	v.Check(validation.In(block.Type, BlockTypes...), "text_blocks", "invalid block type")
+	v.Check(block.Text != "", "text_blocks", "must not contain empty elements")
+	v.Check(len(block.Text) <= 5000, "text_blocks", "block text must not be more than 5000 bytes long")
+	v.Check(len(block.ID) <= 32, "text_blocks", "block id must not be more than 32 bytes long")
+
+	v.Check(block.Checked == nil || block.Type == BlockChecklist, "text_blocks", "only checklist blocks can be checked")
+	v.Check(block.Level == 0 || block.Type == BlockHeading, "text_blocks", "only heading blocks can have a level")
+	v.Check(block.Language == "" || block.Type == BlockCode, "text_blocks", "only code blocks can have a language")
+	v.Check(block.URL == "" || block.Type == BlockLink, "text_blocks", "only link blocks can have an url")
+
+	switch block.Type {
+	case BlockHeading:
+		v.Check(block.Level >= 1 && block.Level <= 3, "text_blocks", "heading level must be between 1 and 3")
+	case BlockLink:
+		u, err := url.Parse(block.URL)
+		v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"text_blocks", "link must have an absolute http or https url")
+	}
+}
@@ -0,0 +1,110 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// maxCalendarEvents ограничивает количество событий в экспорте календаря
+const maxCalendarEvents = 5000
+
+// CalendarEvent событие для экспорта в календарь. Серия экспортируется одним
+// событием с правилом повторения: Overrides содержит её изменённые повторения,
+// а Cancelled - дни отменённых повторений
+type CalendarEvent struct {
+	*Event
+	Overrides []*Event
+	Cancelled []time.Time
+}
+
+// GetCalendar возвращает события пользователя, отобранные по f, без разбиения
+// на страницы и без развёртывания серий. Серии, начавшиеся до f.DateFrom,
+// попадают в выборку, так как их повторения могут приходиться на диапазон.
+// Если событий больше maxCalendarEvents, возвращает ErrTooManyEvents
+func (e EventModel) GetCalendar(ownerId int64, f EventFilters) ([]*CalendarEvent, error) {
+	q := fmt.Sprintf(`
+        select %s
+        from events
+        where %s
+        order by date, id
+        limit %d`, eventColumns, eventFilterConditions, maxCalendarEvents+1)
+
+	f.AllSeries = !f.DateFrom.IsZero()
+	args := f.args(ownerId)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := e.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		events    []*CalendarEvent
+		seriesIds []int64
+	)
+
+	for rows.Next() {
+		var event Event
+
+		err := rows.Scan(event.columns()...)
+		if err != nil {
+			return nil, err
+		}
+
+		if event.Recurrence != "" {
+			seriesIds = append(seriesIds, event.ID)
+		}
+
+		events = append(events, &CalendarEvent{Event: &event})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(events) > maxCalendarEvents {
+		return nil, ErrTooManyEvents
+	}
+
+	if len(seriesIds) == 0 {
+		return events, nil
+	}
+
+	from, to := f.DateFrom.Time, f.DateTo.Time
+	if to.IsZero() {
+		to = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+	}
+
+	exceptions, err := getExceptions(ctx, e.DB, seriesIds, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		days := make([]time.Time, 0, len(exceptions[event.ID]))
+		for d := range exceptions[event.ID] {
+			days = append(days, d)
+		}
+
+		sort.Slice(days, func(i, j int) bool {
+			return days[i].Before(days[j])
+		})
+
+		for _, d := range days {
+			occ := event.occurrence(d, exceptions[event.ID][d])
+			if occ == nil {
+				event.Cancelled = append(event.Cancelled, d)
+				continue
+			}
+
+			event.Overrides = append(event.Overrides, occ)
+		}
+	}
+
+	return events, nil
+}
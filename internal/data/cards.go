@@ -4,16 +4,31 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"github.com/lib/pq"
+	"fmt"
 	"library/internal/validation"
 	"time"
 )
 
+// Стратегии удаления карточки, в которой есть события
+const (
+	// DeleteRestrict запрещает удаление непустой карточки
+	DeleteRestrict = "restrict"
+	// DeleteCascade удаляет карточку вместе с её событиями
+	DeleteCascade = "cascade"
+	// DeleteMove переносит события в другую карточку перед удалением
+	DeleteMove = "move"
+)
+
+var ErrCardNotEmpty = errors.New("card is not empty")
+
 type Card struct {
-	ID        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Events    Events    `json:"events"`
-	CreatedAt time.Time `json:"-"`
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	Archived    bool      `json:"archived"`
+	Events      []*Event  `json:"events,omitempty"`
+	EventsCount *int64    `json:"events_count,omitempty"`
+	CreatedAt   time.Time `json:"-"`
+	OwnerId     int64     `json:"-"`
 }
 
 type CardModel struct {
@@ -27,38 +42,38 @@ func ValidateCard(v *validation.Validator, card *Card) {
 
 }
 
-func (c CardModel) Get(id int64) (*Card, error) {
+func ValidateCardDeletion(v *validation.Validator, id int64, strategy string, targetId int64) {
+	v.Check(validation.In(strategy, DeleteRestrict, DeleteCascade, DeleteMove), "strategy", "invalid strategy value")
+
+	if strategy == DeleteMove {
+		v.Check(targetId != 0, "target_card_id", "must be provided")
+		v.Check(targetId != id, "target_card_id", "must differ from the deleted card")
+	}
+}
+
+// Get возвращает карточку без событий, события карточки
+// запрашиваются отдельно через EventModel.GetAll
+func (c CardModel) Get(id, ownerId int64) (*Card, error) {
 
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
-	//TODO Добавить пагинацию для events
-	q := `
-		select cards.id,
-			   cards.title,
-			   cards.created_at,
-			   coalesce(
-							   array_agg(row_to_json(events.*))
-							   filter ( where events.id is not null ),
-							   '{}'
-			   ) as events
-		
+
+	q := `select id, title, created_at, owner_id, archived
 		from cards
-				 LEFT JOIN events
-						   ON cards.id = events.card_id
-		WHERE cards.id = $1
-		GROUP BY cards.id, cards.title, cards.created_at;`
+		where id = $1 and owner_id = $2`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
 	var card Card
 
-	err := c.DB.QueryRowContext(ctx, q, id).Scan(
+	err := c.DB.QueryRowContext(ctx, q, id, ownerId).Scan(
 		&card.ID,
 		&card.Title,
 		&card.CreatedAt,
-		pq.Array(&card.Events),
+		&card.OwnerId,
+		&card.Archived,
 	)
 
 	if err != nil {
@@ -73,35 +88,205 @@ func (c CardModel) Get(id int64) (*Card, error) {
 	return &card, nil
 }
 
+// GetAll возвращает карточки пользователя без вложенных событий.
+// Если withEventsCount == true, для каждой карточки считается количество событий
+func (c CardModel) GetAll(title string, archived, withEventsCount bool, ownerId int64, filters Filters) ([]*Card, Metadata, error) {
+	q := fmt.Sprintf(`
+		select count(*) over(), id, title, archived, created_at, owner_id,
+		       case when $4 then (select count(*) from events where events.card_id = cards.id and events.deleted_at is null) end
+		from cards
+		where owner_id = $1
+		and (to_tsvector('simple', title) @@ plainto_tsquery('simple', $2) or $2 = '')
+		and archived = $3
+		order by %s %s, id ASC
+		limit $5 offset $6`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{ownerId, title, archived, withEventsCount, filters.limit(), filters.offset()}
+
+	rows, err := c.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	cards := []*Card{}
+
+	for rows.Next() {
+		var card Card
+
+		err := rows.Scan(
+			&totalRecords,
+			&card.ID,
+			&card.Title,
+			&card.Archived,
+			&card.CreatedAt,
+			&card.OwnerId,
+			&card.EventsCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		cards = append(cards, &card)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return cards, metadata, nil
+}
+
 func (c CardModel) Insert(card *Card) error {
 
-	q := `insert into cards (title) 
-		values ($1)
+	q := `insert into cards (title, owner_id) 
+		values ($1, $2)
 		returning id, created_at`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return c.DB.QueryRowContext(ctx, q, card.Title).Scan(&card.ID, &card.CreatedAt)
+	return c.DB.QueryRowContext(ctx, q, card.Title, card.OwnerId).Scan(&card.ID, &card.CreatedAt)
+}
+
+// Exists проверяет, что карточка существует и принадлежит пользователю
+func (c CardModel) Exists(id, ownerId int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return cardExists(ctx, c.DB, id, ownerId)
+}
+
+func cardExists(ctx context.Context, db querier, id, ownerId int64) (bool, error) {
+	q := `select exists(select 1 from cards where id = $1 and owner_id = $2)`
+
+	var exists bool
+
+	err := db.QueryRowContext(ctx, q, id, ownerId).Scan(&exists)
+	return exists, err
 }
 
 func (c CardModel) Update(card *Card) error {
 	q := `update cards
-		set title=$1
-		where id=$2`
+		set title=$1, archived=$2
+		where id=$3 and owner_id=$4`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := c.DB.ExecContext(ctx, q, card.Title, card.ID)
+	res, err := c.DB.ExecContext(ctx, q, card.Title, card.Archived, card.ID, card.OwnerId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+// Delete удаляет карточку, обрабатывая её события согласно strategy.
+// Для DeleteMove события переносятся в карточку targetId того же пользователя
+func (c CardModel) Delete(id, ownerId int64, strategy string, targetId int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := c.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `select id from cards where id = $1 and owner_id = $2 for update`, id, ownerId).Scan(&id)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return ErrEditConflict
+			return ErrRecordNotFound
 		default:
 			return err
 		}
 	}
 
-	return nil
+	switch strategy {
+	case DeleteRestrict:
+		var count int
+
+		q := `select count(*) from events where card_id = $1 and deleted_at is null`
+
+		err = tx.QueryRowContext(ctx, q, id).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return ErrCardNotEmpty
+		}
+
+		// События в корзине некуда восстановить без карточки, поэтому они удаляются окончательно
+		_, err = tx.ExecContext(ctx, `delete from events where card_id = $1 and deleted_at is not null`, id)
+		if err != nil {
+			return err
+		}
+
+	case DeleteCascade:
+		_, err = tx.ExecContext(ctx, `delete from events where card_id = $1`, id)
+		if err != nil {
+			return err
+		}
+
+	case DeleteMove:
+		var exists bool
+
+		q := `select exists(select 1 from cards where id = $1 and owner_id = $2)`
+
+		err = tx.QueryRowContext(ctx, q, targetId, ownerId).Scan(&exists)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return ErrCardConstraint
+		}
+
+		// События встают после последнего события целевой карточки в прежнем порядке,
+		// иначе их позиции совпали бы с позициями её событий или перемешались с ними
+		q = `update events
+			set card_id = $1, position = target.max_position + moved.ord * $3
+			from (select id, row_number() over (order by position, id) as ord
+			      from events where card_id = $2) moved,
+			     (select coalesce(max(position), 0) as max_position
+			      from events where card_id = $1) target
+			where events.id = moved.id`
+
+		_, err = tx.ExecContext(ctx, q, targetId, id, positionGap)
+		if err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown card delete strategy %q", strategy)
+	}
+
+	_, err = tx.ExecContext(ctx, `delete from cards where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,216 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"library/internal/validation"
+	"time"
+)
+
+var ErrInvalidParent = errors.New("parent comment is not in the event")
+
+// Comment комментарий к событию. Ответ на другой комментарий того же события
+// задаётся через ParentId, при удалении комментария удаляются и ответы на него
+type Comment struct {
+	ID        int64     `json:"id"`
+	EventId   int64     `json:"event_id"`
+	ParentId  *int64    `json:"parent_id,omitempty"`
+	Author    Author    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int64     `json:"version"`
+	// RepliesCount количество прямых ответов на комментарий
+	RepliesCount int64 `json:"replies_count"`
+}
+
+// Author автор комментария
+type Author struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// eventCommentsCountColumn количество комментариев события для eventColumns
+const eventCommentsCountColumn = `(select count(*) from comments c where c.event_id = events.id)`
+
+func ValidateComment(v *validation.Validator, comment *Comment) {
+	v.Check(comment.Body != "", "body", "must be provided")
+	v.Check(len(comment.Body) <= 10_000, "body", "must not be more than 10000 bytes long")
+
+	if comment.ParentId != nil {
+		v.Check(*comment.ParentId > 0, "parent_id", "must be a positive id")
+	}
+}
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+const commentColumns = `c.id, c.event_id, c.parent_id, c.author_id, u.name, c.body, c.created_at, c.updated_at, c.version,
+		(select count(*) from comments r where r.parent_id = c.id)`
+
+func (c *Comment) columns() []interface{} {
+	return []interface{}{
+		&c.ID,
+		&c.EventId,
+		&c.ParentId,
+		&c.Author.ID,
+		&c.Author.Name,
+		&c.Body,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.Version,
+		&c.RepliesCount,
+	}
+}
+
+// GetAll возвращает ответы на комментарий parentId или, если parentId == nil,
+// комментарии верхнего уровня события
+func (m CommentModel) GetAll(eventId int64, parentId *int64, f Filters) ([]*Comment, Metadata, error) {
+	q := fmt.Sprintf(`
+		select count(*) over(), %s
+		from comments c
+		join users u on u.id = c.author_id
+		where c.event_id = $1
+		and (c.parent_id = $2 or ($2::bigint is null and c.parent_id is null))
+		order by c.%s %s, c.id ASC
+		limit $3 offset $4`, commentColumns, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, q, eventId, parentId, f.limit(), f.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	comments := []*Comment{}
+
+	for rows.Next() {
+		var comment Comment
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, comment.columns()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		comments = append(comments, &comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return comments, calculateMetadata(totalRecords, f.Page, f.PageSize), nil
+}
+
+func (m CommentModel) Get(id, eventId int64) (*Comment, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	q := `select ` + commentColumns + `
+		from comments c
+		join users u on u.id = c.author_id
+		where c.id = $1 and c.event_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var comment Comment
+
+	err := m.DB.QueryRowContext(ctx, q, id, eventId).Scan(comment.columns()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &comment, nil
+}
+
+// Insert сохраняет комментарий. Возвращает ErrInvalidParent, если комментария
+// ParentId нет среди комментариев того же события
+func (m CommentModel) Insert(comment *Comment) error {
+	q := `insert into comments (event_id, parent_id, author_id, body)
+		select $1, $2, $3, $4
+		where $2::bigint is null or exists (select 1 from comments where id = $2 and event_id = $1)
+		returning id, created_at, updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{comment.EventId, comment.ParentId, comment.Author.ID, comment.Body}
+
+	err := m.DB.QueryRowContext(ctx, q, args...).Scan(&comment.ID, &comment.CreatedAt, &comment.UpdatedAt, &comment.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrInvalidParent
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update изменяет текст комментария его автора, ErrEditConflict - если версия уже изменилась
+func (m CommentModel) Update(comment *Comment) error {
+	q := `update comments
+		set body = $1, updated_at = now(), version = version + 1
+		where id = $2 and author_id = $3 and version = $4
+		returning updated_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{comment.Body, comment.ID, comment.Author.ID, comment.Version}
+
+	err := m.DB.QueryRowContext(ctx, q, args...).Scan(&comment.UpdatedAt, &comment.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete удаляет комментарий автора authorId вместе с ответами на него
+func (m CommentModel) Delete(id, authorId int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	q := `delete from comments where id = $1 and author_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, q, id, authorId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package data
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"time"
 )
@@ -12,14 +13,22 @@ var ErrInvalidDateFormat = errors.New("invalid date format")
 
 const (
 	layout = "2006-01-02"
+	// clockLayout формат времени суток со смещением, в котором хранится due_time
+	clockLayout = "15:04:05-07:00"
 )
 
+// Date дата события. Если Timed == false, событие длится весь день и в JSON
+// передаётся как "2006-01-02", иначе - как RFC 3339 со смещением часового пояса
 type Date struct {
 	time.Time
+	Timed bool
 }
 
 func (t Date) MarshalJSON() ([]byte, error) {
 	stamp := t.Format(layout)
+	if t.Timed {
+		stamp = t.Format(time.RFC3339)
+	}
 	qStamp := strconv.Quote(stamp)
 	return []byte(qStamp), nil
 }
@@ -32,15 +41,86 @@ func (t *Date) UnmarshalJSON(jsonVal []byte) error {
 	}
 
 	date, err := time.Parse(layout, unquoteVal)
+	if err == nil {
+		t.Time = date
+		t.Timed = false
+		return nil
+	}
+
+	date, err = time.Parse(time.RFC3339, unquoteVal)
 	if err != nil {
 		return ErrInvalidDateFormat
 	}
 
 	t.Time = date
+	t.Timed = true
+
+	return nil
+}
+
+// day возвращает день события для столбца date
+func (t Date) day() string {
+	return t.Format(layout)
+}
+
+// clock возвращает время суток для столбца due_time или nil для события на весь день
+func (t Date) clock() interface{} {
+	if !t.Timed {
+		return nil
+	}
+
+	return t.Format(clockLayout)
+}
+
+// clockScanner читает столбец due_time в Date, день в которую
+// уже должен быть прочитан из столбца date
+type clockScanner struct {
+	date *Date
+}
+
+func (c clockScanner) Scan(src any) error {
+	var clock time.Time
+
+	switch v := src.(type) {
+	case nil:
+		c.date.Timed = false
+		return nil
+	case time.Time:
+		clock = v
+	case []byte:
+		return c.Scan(string(v))
+	case string:
+		var err error
+		for _, l := range []string{"15:04:05-07:00", "15:04:05-07", "15:04:05.999999-07:00", "15:04:05.999999-07"} {
+			clock, err = time.Parse(l, v)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid due_time value %q", v)
+		}
+	default:
+		return fmt.Errorf("unsupported due_time type %T", src)
+	}
+
+	day := c.date.Time
+	c.date.Time = time.Date(day.Year(), day.Month(), day.Day(),
+		clock.Hour(), clock.Minute(), clock.Second(), 0, clock.Location())
+	c.date.Timed = true
 
 	return nil
 }
 
+// nullable возвращает nil для нулевой даты, чтобы передать в запрос NULL
+func (t Date) nullable() interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.Time
+}
+
 func (t Date) CheckYear() bool {
 	return t.Year() >= time.Now().Year()
 }
@@ -66,3 +146,21 @@ func (t Date) CheckDay() bool {
 
 	return true
 }
+
+// nullDateScanner читает столбец типа date, который может быть null
+type nullDateScanner struct {
+	date **Date
+}
+
+func (n nullDateScanner) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*n.date = nil
+	case time.Time:
+		*n.date = &Date{Time: v}
+	default:
+		return fmt.Errorf("unsupported date type %T", src)
+	}
+
+	return nil
+}
@@ -7,32 +7,145 @@ import (
 	"errors"
 	"fmt"
 	"github.com/lib/pq"
+	"library/internal/rrule"
 	"library/internal/validation"
+	"strconv"
 	"time"
 )
 
 const titleUniqueConstraintName = "events_title_check"
+const icalUIDUniqueConstraintName = "events_ical_uid_key"
 const cardIdFKConstraint = "fk_card"
 
 var ErrDuplicateTitle = errors.New("duplicate title")
+var ErrDuplicateUID = errors.New("duplicate calendar uid")
 var ErrCardConstraint = errors.New("card is not present in the table")
 
+// Статусы события
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+	StatusCancelled  = "cancelled"
+)
+
+var Statuses = []string{StatusTodo, StatusInProgress, StatusDone, StatusCancelled}
+
+// statusTransitions допустимые переходы между статусами
+var statusTransitions = map[string][]string{
+	StatusTodo:       {StatusInProgress, StatusDone, StatusCancelled},
+	StatusInProgress: {StatusTodo, StatusDone, StatusCancelled},
+	StatusDone:       {StatusTodo, StatusInProgress},
+	StatusCancelled:  {StatusTodo},
+}
+
 type Event struct {
-	ID          int64     `json:"id" `
-	CreatedAt   time.Time `json:"-"`
-	Title       string    `json:"title"`
-	Description string    `json:"description,omitempty"`
-	TextBlocks  []string  `json:"text_blocks,omitempty"`
-	Date        Date      `json:"date,omitempty"`
-	Version     int64     `json:"version,omitempty"`
-	CardId      int64     `json:"card_id"`
+	ID          int64      `json:"id" `
+	CreatedAt   time.Time  `json:"-"`
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	TextBlocks  Blocks     `json:"text_blocks,omitempty"`
+	Date        Date       `json:"date,omitempty"`
+	Version     int64      `json:"version,omitempty"`
+	CardId      int64      `json:"card_id"`
+	OwnerId     int64      `json:"-"`
+	Status      string     `json:"status"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Priority    Priority   `json:"priority"`
+	// Position порядок события внутри карточки, меньшие значения идут первыми
+	Position float64 `json:"position"`
+	// Recurrence правило повторения в формате RRULE, пустая строка - событие не повторяется
+	Recurrence string `json:"recurrence,omitempty"`
+	// SeriesId исходная серия, от которой отделено это событие
+	SeriesId *int64 `json:"series_id,omitempty"`
+	// OccurrenceDate день повторения, заполняется при развёртывании серии
+	OccurrenceDate *Date `json:"occurrence_date,omitempty"`
+	Tags           Tags  `json:"tags"`
+	// DeletedAt время перемещения события в корзину
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	CommentsCount int64      `json:"comments_count"`
+	// ICalUID UID события в календаре, из которого оно импортировано
+	ICalUID string `json:"ical_uid,omitempty"`
+	// prevStatus статус, сохранённый в базе, используется для проверки перехода
+	prevStatus string
+	// Relevance и Headline заполняются только при полнотекстовом поиске
+	Relevance float32 `json:"relevance,omitempty"`
+	Headline  string  `json:"headline,omitempty"`
+}
+
+// MarshalJSON добавляет к событию прогресс по пунктам списка
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+
+	return json.Marshal(struct {
+		event
+		Checklist *ChecklistProgress `json:"checklist,omitempty"`
+	}{
+		event:     event(e),
+		Checklist: e.TextBlocks.Progress(),
+	})
 }
 
 type EventModel struct {
 	DB *sql.DB
 }
 
-type Events []Event
+// eventColumns столбцы событий в порядке, ожидаемом Event.columns
+const eventColumns = `id, created_at, title, description, text_blocks, date, due_time, version, card_id, owner_id,
+		status, completed_at, priority, coalesce(rrule, ''), series_id, position, deleted_at, coalesce(ical_uid, ''), ` +
+	eventTagsColumn + `, ` + eventCommentsCountColumn
+
+func (e *Event) columns() []interface{} {
+	return []interface{}{
+		&e.ID,
+		&e.CreatedAt,
+		&e.Title,
+		&e.Description,
+		&e.TextBlocks,
+		&e.Date.Time,
+		clockScanner{date: &e.Date},
+		&e.Version,
+		&e.CardId,
+		&e.OwnerId,
+		&e.Status,
+		&e.CompletedAt,
+		&e.Priority,
+		&e.Recurrence,
+		&e.SeriesId,
+		&e.Position,
+		&e.DeletedAt,
+		&e.ICalUID,
+		&e.Tags,
+		&e.CommentsCount,
+	}
+}
+
+// Rule возвращает разобранное правило повторения или nil, если событие не повторяется
+func (e *Event) Rule() (*rrule.Rule, error) {
+	if e.Recurrence == "" {
+		return nil, nil
+	}
+
+	return rrule.Parse(e.Recurrence)
+}
+
+// sortValue возвращает значение столбца сортировки для курсора
+func (e *Event) sortValue(column string) string {
+	switch column {
+	case "id":
+		return strconv.FormatInt(e.ID, 10)
+	case "title":
+		return e.Title
+	case "date":
+		return e.Date.day()
+	case "priority":
+		return strconv.Itoa(int(e.Priority))
+	case "position":
+		return strconv.FormatFloat(e.Position, 'g', -1, 64)
+	default:
+		panic("unsupported cursor sort column " + column)
+	}
+}
 
 func ValidateEvent(v *validation.Validator, event *Event) {
 	//Title validation
@@ -44,10 +157,7 @@ func ValidateEvent(v *validation.Validator, event *Event) {
 	v.Check(len(event.Description) <= 1000, "description", "must not be less than 200 bytes long")
 
 	//TextBlocks validation
-	v.Check(event.TextBlocks != nil, "text_blocks", "must be provided")
-	v.Check(len(event.TextBlocks) >= 1, "text_blocks", "must contain at least 1 element")
-	v.Check(len(event.TextBlocks) <= 10, "text_blocks", "must not contain more than 10 elements")
-	v.Check(!validation.In("", event.TextBlocks...), "text_blocks", "must not contain empty elements")
+	ValidateBlocks(v, event.TextBlocks)
 
 	//Date validation
 	v.Check(!event.Date.Time.IsZero(), "date", "must be provided")
@@ -58,59 +168,131 @@ func ValidateEvent(v *validation.Validator, event *Event) {
 	//CardId validation
 	v.Check(event.CardId != 0, "card_id", "must be provided")
 	// Если можно, добавить проверку на то, что такое card_id есть в базе
+
+	v.Check(event.Priority >= PriorityNone && event.Priority <= PriorityUrgent, "priority", "invalid priority value")
+
+	if _, err := event.Rule(); err != nil {
+		v.AddError("recurrence", err.Error())
+	}
+
+	ValidateEventStatus(v, event)
+}
+
+// ValidateEventStatus проверяет статус события и, для уже сохранённого
+// события, допустимость перехода из прежнего статуса
+func ValidateEventStatus(v *validation.Validator, event *Event) {
+	v.Check(validation.In(event.Status, Statuses...), "status", "invalid status value")
+
+	if event.prevStatus != "" && event.prevStatus != event.Status {
+		v.Check(validation.In(event.Status, statusTransitions[event.prevStatus]...), "status",
+			fmt.Sprintf("cannot change from %s to %s", event.prevStatus, event.Status))
+	}
 }
 
 func (e EventModel) Insert(event *Event) error {
-	q := `insert into events (title, description, text_blocks, date, card_id)
-			values ($1, $2, $3, $4, $5)
-			returning id, created_at, version`
-	args := []interface{}{event.Title, event.Description, pq.Array(event.TextBlocks), event.Date.Time, event.CardId}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
+	return insertEvent(ctx, e.DB, event)
+}
+
+// InsertWithTags создаёт событие с метками ids в одной транзакции.
+// ErrTagNotFound - если какой-то метки у пользователя нет
+func (e EventModel) InsertWithTags(event *Event, ids []int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := e.DB.QueryRowContext(ctx, q, args...).Scan(&event.ID, &event.CreatedAt, &event.Version)
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = insertEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
 
-	var pgErr *pq.Error
-	if errors.As(err, &pgErr) {
+	if len(ids) > 0 {
+		err = setEventTags(ctx, tx, event, ids)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func insertEvent(ctx context.Context, db querier, event *Event) error {
+	event.normalizeRecurrence()
+	event.TextBlocks.normalize()
+
+	// Событие можно добавить только в карточку, принадлежащую тому же пользователю.
+	// Новое событие становится последним в карточке
+	q := `insert into events (title, description, text_blocks, date, card_id, owner_id, status, completed_at,
+                    due_time, priority, rrule, series_id, position, ical_uid)
+			select $1, $2, $3, $4, $5, $6, $7, case when $7 = 'done' then now() end, $8, $9, nullif($10, ''), $11,
+			       coalesce((select max(position) from events where card_id = $5), 0) + $12, nullif($13, '')
+			where exists (select 1 from cards where id = $5 and owner_id = $6)
+			returning id, created_at, version, completed_at, position`
+	args := []interface{}{
+		event.Title,
+		event.Description,
+		event.TextBlocks,
+		event.Date.day(),
+		event.CardId,
+		event.OwnerId,
+		event.Status,
+		event.Date.clock(),
+		event.Priority,
+		event.Recurrence,
+		event.SeriesId,
+		positionGap,
+		event.ICalUID,
+	}
+
+	err := db.QueryRowContext(ctx, q, args...).Scan(&event.ID, &event.CreatedAt, &event.Version, &event.CompletedAt, &event.Position)
+	if err != nil {
+		var pgErr *pq.Error
 		switch {
-		case pgErr.Constraint == titleUniqueConstraintName:
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrCardConstraint
+		case errors.As(err, &pgErr) && pgErr.Constraint == titleUniqueConstraintName:
 			return ErrDuplicateTitle
-		case pgErr.Constraint == cardIdFKConstraint:
+		case errors.As(err, &pgErr) && pgErr.Constraint == icalUIDUniqueConstraintName:
+			return ErrDuplicateUID
+		case errors.As(err, &pgErr) && pgErr.Constraint == cardIdFKConstraint:
 			return fmt.Errorf("%w. %s", ErrCardConstraint, pgErr.Detail)
 		default:
 			return err
 		}
 	}
 
+	event.prevStatus = event.Status
+
 	return nil
 }
 
-func (e EventModel) Get(id int64) (*Event, error) {
+func (e EventModel) Get(id, ownerId int64) (*Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return getEvent(ctx, e.DB, id, ownerId)
+}
+
+func getEvent(ctx context.Context, db querier, id, ownerId int64) (*Event, error) {
 
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
-	q := `select id, created_at, title, description, text_blocks, date, version, card_id 
+	q := `select ` + eventColumns + `
 			from events
-			where id=$1`
+			where id=$1 and owner_id=$2 and deleted_at is null`
 
 	var event Event
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	err := e.DB.QueryRowContext(ctx, q, id).Scan(
-		&event.ID,
-		&event.CreatedAt,
-		&event.Title,
-		&event.Description,
-		pq.Array(&event.TextBlocks),
-		&event.Date.Time,
-		&event.Version,
-		&event.CardId,
-	)
+	err := db.QueryRowContext(ctx, q, id, ownerId).Scan(event.columns()...)
 
 	if err != nil {
 		switch {
@@ -121,26 +303,152 @@ func (e EventModel) Get(id int64) (*Event, error) {
 		}
 	}
 
+	event.prevStatus = event.Status
+
 	return &event, nil
 }
 
-func (e EventModel) GetAll(title string, date Date, filters Filters) ([]*Event, Metadata, error) {
+// Словари полнотекстового поиска
+const (
+	SearchEnglish = "english"
+	SearchRussian = "russian"
+)
+
+// EventFilters набор условий для выборки событий в GetAll
+type EventFilters struct {
+	Title string
+	// Query поисковый запрос по названию, описанию и блокам текста,
+	// Language - словарь, по которому разбирается запрос
+	Query    string
+	Language string
+	// DateFrom и DateTo задают диапазон дат включительно, нулевое значение - без ограничения
+	DateFrom Date
+	DateTo   Date
+	// CardIds ограничивает выборку событиями указанных карточек, пустой список - все карточки
+	CardIds  []int64
+	Statuses []string
+	// Tags названия меток, TagMode - должно ли событие иметь любую из них или все
+	Tags            []string
+	TagMode         string
+	IncludeArchived bool
+	// AllSeries отбирает серии независимо от даты начала: их повторения могут попасть
+	// в выборку, даже если сама серия не попадает. Серии не разворачиваются, поэтому
+	// статус проверяется у самой серии
+	AllSeries bool
+	Filters
+}
+
+func ValidateEventFilters(v *validation.Validator, f EventFilters) {
+	ValidateFilters(v, f.Filters)
+
+	if !f.DateFrom.IsZero() && !f.DateTo.IsZero() {
+		v.Check(!f.DateTo.Before(f.DateFrom.Time), "date_to", "must not be earlier than date_from")
+	}
+
+	v.Check(len(f.CardIds) <= 100, "card_id", "must not contain more than 100 elements")
+
+	v.Check(len(f.Tags) <= 20, "tag", "must not contain more than 20 elements")
+	v.Check(validation.In(f.TagMode, TagModeAny, TagModeAll), "tag_mode", "invalid tag mode value")
+
+	v.Check(validation.In(f.Language, SearchEnglish, SearchRussian), "lang", "invalid language value")
+
+	for _, status := range f.Statuses {
+		v.Check(validation.In(status, Statuses...), "status", "invalid status value")
+	}
+	v.Check(len(f.Query) <= 500, "q", "must not be more than 500 bytes long")
+
+	if f.Sort == "relevance" {
+		v.Check(f.Query != "", "sort", "relevance sort requires the q parameter")
+		v.Check(!f.Keyset, "sort", "relevance sort does not support cursor pagination")
+	}
+}
+
+// sortDirection для сортировки по релевантности всегда начинает с наиболее подходящих событий
+func (f EventFilters) sortDirection() string {
+	if f.Sort == "relevance" {
+		return "DESC"
+	}
+
+	return f.Filters.sortDirection()
+}
+
+// eventFilterConditions условия выборки по EventFilters, параметры $1-$13 задаёт EventFilters.args.
+// При развёртывании повторений и с AllSeries ($10) серии отбираются независимо от даты начала.
+// Статус серии не проверяется ($13) только при развёртывании без AllSeries: его затем проверяет каждое повторение
+const eventFilterConditions = `owner_id = $1
+        and deleted_at is null
+        and (to_tsvector('simple', title) @@ plainto_tsquery('simple', $2) or $2 = '')
+        and ($3::date is null or date >= $3 or ($10 and rrule is not null))
+        and ($4::date is null or date <= $4)
+        and ($5::bigint[] is null or card_id = any($5))
+        and ($6 or not exists(select 1 from cards where cards.id = events.card_id and cards.archived))
+        and ($7 = '' or search_vector @@ websearch_to_tsquery($8::regconfig, $7))
+        and ($9::text[] is null or status = any($9) or ($13 and rrule is not null))
+        and ($11::text[] is null or (
+            select count(distinct t.name) from events_tags et join tags t on t.id = et.tag_id
+            where et.event_id = events.id and t.name = any($11)
+        ) >= case when $12 then cardinality($11) else 1 end)`
+
+// eventSearchColumns релевантность и фрагмент текста с подсвеченными совпадениями
+const eventSearchColumns = `
+        case when $7 <> '' then ts_rank(search_vector, websearch_to_tsquery($8::regconfig, $7)) else 0 end as relevance,
+        case when $7 <> '' then ts_headline($8::regconfig,
+                concat_ws(' ', title, description, events_blocks_text(text_blocks)),
+                websearch_to_tsquery($8::regconfig, $7),
+                'MaxFragments=2, MaxWords=20, MinWords=5') else '' end`
+
+func (f EventFilters) args(ownerId int64) []interface{} {
+	var cardIds, statuses, tags interface{}
+	if len(f.CardIds) > 0 {
+		cardIds = pq.Array(f.CardIds)
+	}
+	if len(f.Statuses) > 0 {
+		statuses = pq.Array(f.Statuses)
+	}
+	if len(f.Tags) > 0 {
+		tags = pq.Array(uniqueStrings(f.Tags))
+	}
+
+	return []interface{}{
+		ownerId,
+		f.Title,
+		f.DateFrom.nullable(),
+		f.DateTo.nullable(),
+		cardIds,
+		f.IncludeArchived,
+		f.Query,
+		f.Language,
+		statuses,
+		f.expand() || f.AllSeries,
+		tags,
+		f.TagMode == TagModeAll,
+		f.expand() && !f.AllSeries,
+	}
+}
+
+// expand сообщает, нужно ли развернуть повторяющиеся события в отдельные повторения.
+// Повторения разворачиваются только для ограниченного с двух сторон диапазона дат
+// при постраничном выводе по номеру страницы
+func (f EventFilters) expand() bool {
+	return !f.DateFrom.IsZero() && !f.DateTo.IsZero() && !f.Keyset
+}
+
+func (e EventModel) GetAll(ownerId int64, f EventFilters) ([]*Event, Metadata, error) {
+	if f.expand() {
+		return e.getAllExpanded(ownerId, f)
+	}
 
-	//TODO: нужно будет пофиксить баг связанный с фильтрацией по дате
-	// если выбрана такая дата date = current_date, то выводятся все элементы
-	// возможно просто нужно задать другое значение по умолчанию
 	q := fmt.Sprintf(`
-        select count(*) over(), id, created_at, title, description, text_blocks, date, version, card_id
+        select count(*) over(), %s, %s
         from events
-        where (to_tsvector('web', title) @@ plainto_tsquery('web', $1) or $1 = '')
-        and (date = $2 or $2 = current_date)
+        where %s
         order by %s %s, id ASC
-        limit $3 offset $4 `, filters.sortColumn(), filters.sortDirection())
+        limit $14 offset $15 `, eventColumns, eventSearchColumns, eventFilterConditions, f.sortColumn(), f.sortDirection())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{title, date.Time, filters.limit(), filters.offset()}
+	args := append(f.args(ownerId), f.limit(), f.offset())
 
 	rows, err := e.DB.QueryContext(ctx, q, args...)
 	if err != nil {
@@ -149,22 +457,14 @@ func (e EventModel) GetAll(title string, date Date, filters Filters) ([]*Event,
 	defer rows.Close()
 
 	totalRecords := 0
-	var events []*Event
+	events := []*Event{}
 
 	for rows.Next() {
 		var event Event
 
-		err := rows.Scan(
-			&totalRecords,
-			&event.ID,
-			&event.CreatedAt,
-			&event.Title,
-			&event.Description,
-			pq.Array(&event.TextBlocks),
-			&event.Date.Time,
-			&event.Version,
-			&event.CardId,
-		)
+		dest := append([]interface{}{&totalRecords}, event.columns()...)
+
+		err := rows.Scan(append(dest, &event.Relevance, &event.Headline)...)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -172,31 +472,172 @@ func (e EventModel) GetAll(title string, date Date, filters Filters) ([]*Event,
 		events = append(events, &event)
 	}
 
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, f.Page, f.PageSize)
+
+	return events, metadata, nil
+}
+
+// GetAllAfter возвращает страницу событий, следующую за f.Cursor.
+// В отличие от GetAll не считает общее количество записей и не зависит
+// от вставок между запросами страниц
+func (e EventModel) GetAllAfter(ownerId int64, f EventFilters) ([]*Event, CursorMetadata, error) {
+	q := fmt.Sprintf(`
+        select %s, %s
+        from events
+        where %s
+        and %s
+        order by %s %s, id %[6]s
+        limit $14`, eventColumns, eventSearchColumns, eventFilterConditions, f.keysetCondition(15, 16),
+		f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	args := append(f.args(ownerId), f.limit()+1)
+	if f.Cursor != nil {
+		args = append(args, f.Cursor.Value, f.Cursor.ID)
+	}
+
+	rows, err := e.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, CursorMetadata{}, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		var event Event
+
+		err := rows.Scan(append(event.columns(), &event.Relevance, &event.Headline)...)
+		if err != nil {
+			return nil, CursorMetadata{}, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, CursorMetadata{}, err
+	}
+
+	metadata := CursorMetadata{PageSize: f.PageSize}
+
+	if len(events) > f.PageSize {
+		events = events[:f.PageSize]
+		last := events[len(events)-1]
+
+		metadata.NextCursor = Cursor{
+			Sort:  f.Sort,
+			Value: last.sortValue(f.sortColumn()),
+			ID:    last.ID,
+		}.Encode()
+	}
 
 	return events, metadata, nil
 }
 
 func (e EventModel) Update(event *Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return updateEvent(ctx, e.DB, event)
+}
+
+// UpdateWithTags сохраняет событие и, если ids != nil, заменяет его метки в одной
+// транзакции, поэтому метки тоже защищены проверкой версии
+func (e EventModel) UpdateWithTags(event *Event, ids []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateEvent(ctx, tx, event)
+	if err != nil {
+		return err
+	}
+
+	if ids != nil {
+		err = setEventTags(ctx, tx, event, ids)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func updateEvent(ctx context.Context, db querier, event *Event) error {
+	event.normalizeRecurrence()
+	event.TextBlocks.normalize()
+
 	q := `update events
-		set title=$1, description=$2, date=$3, text_blocks=$4, version = version + 1, card_id=$5
-		where id=$6 and version=$7
-		returning version`
+		set title=$1, description=$2, date=$3, text_blocks=$4, version = version + 1, card_id=$5,
+		    status=$9, completed_at = case when $9 = 'done' then coalesce(completed_at, now()) end,
+		    due_time=$10, priority=$11, rrule=nullif($12, ''),
+		    position = case when card_id <> $5
+		        then coalesce((select max(e.position) from events e where e.card_id = $5), 0) + $13
+		        else position end
+		where id=$6 and version=$7 and owner_id=$8 and deleted_at is null
+		returning version, completed_at, position`
 
 	args := []interface{}{
 		event.Title,
 		event.Description,
-		event.Date.Time,
-		pq.Array(event.TextBlocks),
+		event.Date.day(),
+		event.TextBlocks,
 		event.CardId,
 		event.ID,
 		event.Version,
+		event.OwnerId,
+		event.Status,
+		event.Date.clock(),
+		event.Priority,
+		event.Recurrence,
+		positionGap,
+	}
+
+	err := db.QueryRowContext(ctx, q, args...).Scan(&event.Version, &event.CompletedAt, &event.Position)
+	if err != nil {
+		var pgErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case errors.As(err, &pgErr) && pgErr.Constraint == titleUniqueConstraintName:
+			return ErrDuplicateTitle
+		default:
+			return err
+		}
 	}
 
+	event.prevStatus = event.Status
+
+	return nil
+}
+
+// UpdateBlocks сохраняет только блоки текста события. Как и Update,
+// возвращает ErrEditConflict, если версия события в базе уже изменилась
+func (e EventModel) UpdateBlocks(event *Event) error {
+	event.TextBlocks.normalize()
+
+	q := `update events
+		set text_blocks = $1, version = version + 1
+		where id = $2 and version = $3 and owner_id = $4 and deleted_at is null
+		returning version`
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := e.DB.QueryRowContext(ctx, q, args...).Scan(&event.Version)
+	err := e.DB.QueryRowContext(ctx, q, event.TextBlocks, event.ID, event.Version, event.OwnerId).Scan(&event.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -209,17 +650,24 @@ func (e EventModel) Update(event *Event) error {
 	return nil
 }
 
-func (e EventModel) Delete(id int64) error {
+// Delete перемещает событие в корзину. Окончательно событие удаляет Purge
+func (e EventModel) Delete(id, ownerId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return deleteEvent(ctx, e.DB, id, ownerId)
+}
+
+func deleteEvent(ctx context.Context, db querier, id, ownerId int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	q := `delete from events
-			where id=$1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	q := `update events
+			set deleted_at = now()
+			where id=$1 and owner_id=$2 and deleted_at is null`
 
-	res, err := e.DB.ExecContext(ctx, q, id)
+	res, err := db.ExecContext(ctx, q, id, ownerId)
 	if err != nil {
 		return err
 	}
@@ -235,19 +683,3 @@ func (e EventModel) Delete(id int64) error {
 
 	return nil
 }
-
-func (e *Event) Scan(src any) error {
-	var data []byte
-	switch v := src.(type) {
-	case string:
-		data = []byte(v)
-	case []byte:
-		data = v
-	}
-
-	return json.Unmarshal(data, e)
-}
-
-func (e *Events) Scan(src any) error {
-	return pq.GenericArray{A: e}.Scan(src)
-}
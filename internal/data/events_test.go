@@ -0,0 +1,61 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventFilterArgsSeries(t *testing.T) {
+	from := Date{Time: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)}
+	to := Date{Time: time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name      string
+		filters   EventFilters
+		anyStart  bool
+		anyStatus bool
+	}{
+		{
+			name:    "no dates",
+			filters: EventFilters{Statuses: []string{"done"}},
+		},
+		{
+			name:      "expanded listing",
+			filters:   EventFilters{Statuses: []string{"done"}, DateFrom: from, DateTo: to},
+			anyStart:  true,
+			anyStatus: true,
+		},
+		{
+			name:    "cursor listing is not expanded",
+			filters: EventFilters{Statuses: []string{"done"}, DateFrom: from, DateTo: to, Filters: Filters{Keyset: true}},
+		},
+		{
+			name:     "calendar with status and date_from",
+			filters:  EventFilters{Statuses: []string{"done"}, DateFrom: from, AllSeries: true},
+			anyStart: true,
+		},
+		{
+			name:     "calendar with status and date range",
+			filters:  EventFilters{Statuses: []string{"done"}, DateFrom: from, DateTo: to, AllSeries: true},
+			anyStart: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.filters.args(1)
+
+			if len(args) != 13 {
+				t.Fatalf("got %d args, want 13", len(args))
+			}
+
+			if got := args[9].(bool); got != tt.anyStart {
+				t.Errorf("series with any start date ($10) = %v, want %v", got, tt.anyStart)
+			}
+
+			if got := args[12].(bool); got != tt.anyStatus {
+				t.Errorf("series with any status ($13) = %v, want %v", got, tt.anyStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Feed календарная подписка: токен области ScopeFeed, открывающий календарь
+// всех событий пользователя или, если задан CardId, событий одной карточки.
+// Token заполняется только при создании, в базе хранится лишь хэш
+type Feed struct {
+	ID        int64     `json:"id"`
+	UserId    int64     `json:"-"`
+	CardId    *int64    `json:"card_id,omitempty"`
+	Token     string    `json:"token,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+type FeedModel struct {
+	DB *sql.DB
+}
+
+const feedColumns = `id, user_id, card_id, created_at, expiry`
+
+func (f *Feed) columns() []interface{} {
+	return []interface{}{
+		&f.ID,
+		&f.UserId,
+		&f.CardId,
+		&f.CreatedAt,
+		&f.Expiry,
+	}
+}
+
+// New создаёт подписку пользователя на события карточки cardId или, если cardId == nil, на все события
+func (m FeedModel) New(userId int64, cardId *int64) (*Feed, error) {
+	token, err := generateToken(userId, FeedTokenDuration, ScopeFeed)
+	if err != nil {
+		return nil, err
+	}
+
+	q := `insert into tokens (hash, user_id, expiry, scope, card_id)
+			values ($1, $2, $3, $4, $5)
+			returning id, created_at`
+
+	args := []interface{}{token.Hash, token.UserId, token.Expiry, token.Scope, cardId}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	feed := &Feed{
+		UserId: userId,
+		CardId: cardId,
+		Token:  token.PlainText,
+		Expiry: token.Expiry,
+	}
+
+	err = m.DB.QueryRowContext(ctx, q, args...).Scan(&feed.ID, &feed.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// GetForToken возвращает действующую подписку по токену из адреса
+func (m FeedModel) GetForToken(tokenPlainText string) (*Feed, error) {
+	hash := sha256.Sum256([]byte(tokenPlainText))
+
+	q := `select ` + feedColumns + `
+		from tokens
+		where hash = $1 and scope = $2 and expiry > now()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var feed Feed
+
+	err := m.DB.QueryRowContext(ctx, q, hash[:], ScopeFeed).Scan(feed.columns()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &feed, nil
+}
+
+// GetAll возвращает подписки пользователя, включая истёкшие.
+// Если cardId != nil, только подписки на эту карточку
+func (m FeedModel) GetAll(userId int64, cardId *int64) ([]*Feed, error) {
+	q := `select ` + feedColumns + `
+		from tokens
+		where user_id = $1 and scope = $2 and ($3::bigint is null or card_id = $3)
+		order by created_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, q, userId, ScopeFeed, cardId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []*Feed{}
+
+	for rows.Next() {
+		var feed Feed
+
+		err := rows.Scan(feed.columns()...)
+		if err != nil {
+			return nil, err
+		}
+
+		feeds = append(feeds, &feed)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return feeds, nil
+}
+
+// Delete отзывает подписку пользователя
+func (m FeedModel) Delete(id, userId int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	q := `delete from tokens where id = $1 and user_id = $2 and scope = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, q, id, userId, ScopeFeed)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteAll отзывает все подписки пользователя или, если cardId != nil,
+// подписки на карточку и возвращает количество отозванных
+func (m FeedModel) DeleteAll(userId int64, cardId *int64) (int64, error) {
+	q := `delete from tokens where user_id = $1 and scope = $2 and ($3::bigint is null or card_id = $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, q, userId, ScopeFeed, cardId)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -1,11 +1,17 @@
 package data
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"library/internal/validation"
 	"math"
 	"strings"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Filters struct {
 	Page     int
 	PageSize int
@@ -14,6 +20,45 @@ type Filters struct {
 	// используется для определения сортировки по убыванию
 	Sort         string
 	SortSafeList []string
+	// Keyset включает постраничный вывод по курсору вместо limit/offset.
+	// Cursor == nil означает первую страницу
+	Keyset bool
+	Cursor *Cursor
+}
+
+// Cursor указывает на последнюю запись предыдущей страницы:
+// значение столбца сортировки и id для однозначного порядка
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+
+	err = json.Unmarshal(js, &c)
+	if err != nil || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// CursorMetadata метаданные страницы при выводе по курсору
+type CursorMetadata struct {
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type Metadata struct {
@@ -65,13 +110,35 @@ func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
+// keysetCondition возвращает условие для выборки записей после курсора.
+// valueArg и idArg - номера параметров запроса со значениями курсора
+func (f Filters) keysetCondition(valueArg, idArg int) string {
+	if f.Cursor == nil {
+		return "true"
+	}
+
+	op := ">"
+	if f.sortDirection() == "DESC" {
+		op = "<"
+	}
+
+	return fmt.Sprintf("(%[1]s %[2]s $%[3]d or (%[1]s = $%[3]d and id %[2]s $%[4]d))",
+		f.sortColumn(), op, valueArg, idArg)
+}
+
 func ValidateFilters(v *validation.Validator, filters Filters) {
 
-	v.Check(filters.Page >= 1, "page", "must be greater than or equal to 1")
-	v.Check(filters.Page <= 10_000_000, "page", "must be less than or equal to 10 000 000")
+	if filters.Keyset {
+		if filters.Cursor != nil {
+			v.Check(filters.Cursor.Sort == filters.Sort, "cursor", "does not match the sort parameter")
+		}
+	} else {
+		v.Check(filters.Page >= 1, "page", "must be greater than or equal to 1")
+		v.Check(filters.Page <= 10_000_000, "page", "must be less than or equal to 10 000 000")
+	}
 
 	v.Check(filters.PageSize >= 1, "page_size", "must be greater than or equal to 1")
-	v.Check(filters.Page <= 100, "page_size", "must be less than or equal to 100")
+	v.Check(filters.PageSize <= 100, "page_size", "must be less than or equal to 100")
 
 	v.Check(validation.In(filters.Sort, filters.SortSafeList...), "sort", "invalid sort value")
 
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 )
@@ -10,12 +11,27 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// querier общие методы *sql.DB и *sql.Tx, чтобы одни и те же запросы
+// можно было выполнять как отдельно, так и внутри транзакции
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
 type Models struct {
 	Events      EventModel
 	Cards       CardModel
 	Users       UserModel
 	Tokens      TokenModel
 	Permissions PermissionsModel
+	Roles       RoleModel
+	Reminders   ReminderModel
+	Tags        TagModel
+	Revisions   RevisionModel
+	Comments    CommentModel
+	Attachments AttachmentModel
+	Feeds       FeedModel
 }
 
 func NewModels(db *sql.DB) Models {
@@ -25,5 +41,12 @@ func NewModels(db *sql.DB) Models {
 		Users:       UserModel{DB: db},
 		Tokens:      TokenModel{DB: db},
 		Permissions: PermissionsModel{DB: db},
+		Roles:       RoleModel{DB: db},
+		Reminders:   ReminderModel{DB: db},
+		Tags:        TagModel{DB: db},
+		Revisions:   RevisionModel{DB: db},
+		Comments:    CommentModel{DB: db},
+		Attachments: AttachmentModel{DB: db},
+		Feeds:       FeedModel{DB: db},
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"github.com/lib/pq"
+	"library/internal/validation"
 	"time"
 )
 
@@ -22,25 +23,67 @@ func (p Permissions) Include(permission string) bool {
 	return false
 }
 
-// GetPermissionsForRole
+// ValidatePermissionNames проверяет, что все переданные права существуют
+func ValidatePermissionNames(v *validation.Validator, permissions []string, known Permissions) {
+	v.Check(len(permissions) >= 1, "permissions", "must contain at least 1 element")
+	v.Check(validation.Unique(permissions), "permissions", "must not contain duplicate values")
 
+	for _, permission := range permissions {
+		v.Check(known.Include(permission), "permissions", "contains unknown permission "+permission)
+	}
+}
+
+func (p PermissionsModel) GetAll() (Permissions, error) {
+	q := `select permission from permissions order by id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return p.query(ctx, q)
+}
+
+// GetGrantedForUser возвращает только права, выданные пользователю напрямую
+func (p PermissionsModel) GetGrantedForUser(userId int64) (Permissions, error) {
+	q := `select p.permission
+            from permissions as p
+            inner join users_permissions as up on p.id = up.permission_id
+            where up.user_id = $1
+            order by p.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return p.query(ctx, q, userId)
+}
+
+// GetForUser возвращает итоговый набор прав пользователя:
+// права, выданные напрямую, и права всех его ролей
 func (p PermissionsModel) GetForUser(userId int64) (Permissions, error) {
 	q := `select p.permission
             from permissions as p
             inner join users_permissions as up on p.id = up.permission_id
-            inner join users as u on up.user_id = u.id
-            where u.id = $1`
+            where up.user_id = $1
+            union
+            select p.permission
+            from permissions as p
+            inner join roles_permissions as rp on p.id = rp.permission_id
+            inner join users_roles as ur on rp.role_id = ur.role_id
+            where ur.user_id = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := p.DB.QueryContext(ctx, q, userId)
+	return p.query(ctx, q, userId)
+}
+
+func (p PermissionsModel) query(ctx context.Context, q string, args ...interface{}) (Permissions, error) {
+	rows, err := p.DB.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var perm Permissions
+	perm := Permissions{}
 
 	for rows.Next() {
 		var permission string
@@ -59,7 +102,20 @@ func (p PermissionsModel) GetForUser(userId int64) (Permissions, error) {
 
 func (p PermissionsModel) Grant(userId int64, permissions ...string) error {
 	q := `insert into users_permissions (user_id, permission_id)
-		select $1, p.id from permissions as p where p.permission = any($2)`
+		select $1, p.id from permissions as p where p.permission = any($2)
+		on conflict do nothing`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := p.DB.ExecContext(ctx, q, userId, pq.Array(permissions))
+	return err
+}
+
+func (p PermissionsModel) Revoke(userId int64, permissions ...string) error {
+	q := `delete from users_permissions
+		where user_id = $1
+		and permission_id in (select id from permissions where permission = any($2))`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -0,0 +1,190 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"library/internal/validation"
+	"time"
+)
+
+// positionGap промежуток между позициями соседних событий после нормализации
+const positionGap = 1024
+
+// minPositionGap минимальный промежуток, при котором новую позицию ещё можно
+// взять посередине между соседями. При меньшем промежутке позиции карточки
+// нормализуются
+const minPositionGap = 1e-6
+
+var ErrInvalidAnchor = errors.New("anchor event is not in the target card")
+
+// MoveTarget куда переместить событие: в карточку CardId перед событием Before
+// или после события After. Если соседи не заданы, событие становится последним
+type MoveTarget struct {
+	CardId int64
+	Before int64
+	After  int64
+}
+
+func ValidateMoveTarget(v *validation.Validator, event *Event, target MoveTarget) {
+	v.Check(target.CardId > 0, "card_id", "must be a positive id")
+	v.Check(target.Before == 0 || target.After == 0, "before", "must not be combined with after")
+	v.Check(target.Before != event.ID, "before", "must differ from the moved event")
+	v.Check(target.After != event.ID, "after", "must differ from the moved event")
+}
+
+// Move перемещает событие согласно target, увеличивая его версию.
+// Возвращает ErrInvalidAnchor, если соседнее событие не находится в карточке,
+// и ErrEditConflict, если событие было изменено параллельно
+func (e EventModel) Move(event *Event, target MoveTarget) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка событий карточки не даёт параллельным перемещениям
+	// занять одну и ту же позицию
+	q := `select id, position from events
+		where card_id = $1 and owner_id = $2 and id <> $3 and deleted_at is null
+		order by position, id
+		for update`
+
+	rows, err := tx.QueryContext(ctx, q, target.CardId, event.OwnerId, event.ID)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ids       []int64
+		positions []float64
+	)
+
+	for rows.Next() {
+		var (
+			id       int64
+			position float64
+		)
+
+		if err := rows.Scan(&id, &position); err != nil {
+			rows.Close()
+			return err
+		}
+
+		ids = append(ids, id)
+		positions = append(positions, position)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	index, err := insertIndex(ids, target)
+	if err != nil {
+		return err
+	}
+
+	position, ok := positionAt(positions, index)
+	if !ok {
+		positions, err = normalizePositions(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+
+		position, _ = positionAt(positions, index)
+	}
+
+	q = `update events
+		set card_id = $1, position = $2, version = version + 1
+		where id = $3 and version = $4 and owner_id = $5 and deleted_at is null
+		returning version`
+
+	err = tx.QueryRowContext(ctx, q, target.CardId, position, event.ID, event.Version, event.OwnerId).Scan(&event.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	event.CardId = target.CardId
+	event.Position = position
+
+	return nil
+}
+
+// insertIndex возвращает индекс в ids, на который встанет перемещаемое событие
+func insertIndex(ids []int64, target MoveTarget) (int, error) {
+	anchor := target.Before
+	if target.After != 0 {
+		anchor = target.After
+	}
+
+	if anchor == 0 {
+		return len(ids), nil
+	}
+
+	for i, id := range ids {
+		if id == anchor {
+			if target.After != 0 {
+				return i + 1, nil
+			}
+			return i, nil
+		}
+	}
+
+	return 0, ErrInvalidAnchor
+}
+
+// positionAt вычисляет позицию между соседями positions[index-1] и positions[index].
+// Возвращает false, если промежуток между ними слишком мал
+func positionAt(positions []float64, index int) (float64, bool) {
+	switch {
+	case len(positions) == 0:
+		return positionGap, true
+	case index == 0:
+		return positions[0] - positionGap, true
+	case index == len(positions):
+		return positions[index-1] + positionGap, true
+	}
+
+	prev, next := positions[index-1], positions[index]
+	if next-prev < minPositionGap {
+		return 0, false
+	}
+
+	return prev + (next-prev)/2, true
+}
+
+// normalizePositions расставляет событиям ids позиции с шагом positionGap в их текущем порядке
+func normalizePositions(ctx context.Context, tx *sql.Tx, ids []int64) ([]float64, error) {
+	q := `update events
+		set position = ordered.ord * $2
+		from unnest($1::bigint[]) with ordinality as ordered(id, ord)
+		where events.id = ordered.id`
+
+	_, err := tx.ExecContext(ctx, q, pq.Array(ids), positionGap)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]float64, len(ids))
+	for i := range positions {
+		positions[i] = float64(i+1) * positionGap
+	}
+
+	return positions, nil
+}
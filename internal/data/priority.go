@@ -0,0 +1,50 @@
+package data
+
+import (
+	"errors"
+	"strconv"
+)
+
+var ErrInvalidPriority = errors.New("invalid priority")
+
+// Priority срочность события, в базе хранится числом,
+// чтобы сортировка по priority шла от менее срочных к более срочным
+type Priority int16
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+func (p Priority) String() string {
+	if p < PriorityNone || p > PriorityUrgent {
+		return ""
+	}
+
+	return priorityNames[p]
+}
+
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(p.String())), nil
+}
+
+func (p *Priority) UnmarshalJSON(jsonVal []byte) error {
+	unquoteVal, err := strconv.Unquote(string(jsonVal))
+	if err != nil {
+		return ErrInvalidPriority
+	}
+
+	for i, name := range priorityNames {
+		if name == unquoteVal {
+			*p = Priority(i)
+			return nil
+		}
+	}
+
+	return ErrInvalidPriority
+}
@@ -0,0 +1,476 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"library/internal/validation"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Области изменения повторяющегося события
+const (
+	ScopeAll        = "all"
+	ScopeOccurrence = "occurrence"
+	ScopeFollowing  = "following"
+)
+
+// maxOccurrences ограничивает количество повторений одной серии в выборке
+const maxOccurrences = 1000
+
+// ErrTooManyEvents выборка превышает ограничения на количество событий или повторений,
+// и её нельзя вернуть целиком без потери событий
+var ErrTooManyEvents = errors.New("too many events")
+
+// EventException изменения одного повторения серии. Поля, равные nil,
+// берутся из самой серии
+type EventException struct {
+	EventId        int64
+	OccurrenceDate time.Time
+	Cancelled      bool
+	Title          *string
+	Description    *string
+	Date           *Date
+	Status         *string
+	Priority       *Priority
+}
+
+// occurrence возвращает повторение события в день d с учётом изменений ex
+// или nil, если повторение отменено
+func (e *Event) occurrence(d time.Time, ex *EventException) *Event {
+	if ex != nil && ex.Cancelled {
+		return nil
+	}
+
+	occ := *e
+	occ.Date.Time = time.Date(d.Year(), d.Month(), d.Day(),
+		e.Date.Hour(), e.Date.Minute(), e.Date.Second(), 0, e.Date.Location())
+	occ.OccurrenceDate = &Date{Time: d}
+
+	if ex == nil {
+		occ.prevStatus = occ.Status
+		return &occ
+	}
+
+	if ex.Title != nil {
+		occ.Title = *ex.Title
+	}
+
+	if ex.Description != nil {
+		occ.Description = *ex.Description
+	}
+
+	if ex.Date != nil {
+		occ.Date = *ex.Date
+	}
+
+	if ex.Status != nil {
+		occ.Status = *ex.Status
+	}
+
+	if ex.Priority != nil {
+		occ.Priority = *ex.Priority
+	}
+
+	occ.prevStatus = occ.Status
+
+	return &occ
+}
+
+// Occurrence возвращает повторение серии в день d или ErrRecordNotFound,
+// если на этот день повторение не приходится или оно отменено
+func (e EventModel) Occurrence(event *Event, d time.Time) (*Event, error) {
+	rule, err := event.Rule()
+	if err != nil {
+		return nil, err
+	}
+
+	if rule == nil || !rule.Includes(event.Date.Time, d) {
+		return nil, ErrRecordNotFound
+	}
+
+	d = time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	exceptions, err := getExceptions(ctx, e.DB, []int64{event.ID}, d, d)
+	if err != nil {
+		return nil, err
+	}
+
+	occ := event.occurrence(d, exceptions[event.ID][d])
+	if occ == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	return occ, nil
+}
+
+// normalizeRecurrence приводит правило повторения к каноничному виду
+func (e *Event) normalizeRecurrence() {
+	rule, err := e.Rule()
+	if err == nil && rule != nil {
+		e.Recurrence = rule.String()
+	}
+}
+
+// getExceptions возвращает изменения повторений событий ids в диапазоне дней [from, to]
+func getExceptions(ctx context.Context, db querier, ids []int64, from, to time.Time) (map[int64]map[time.Time]*EventException, error) {
+	q := `select event_id, occurrence_date, cancelled, title, description, date, due_time, status, priority
+		from event_exceptions
+		where event_id = any($1) and occurrence_date between $2 and $3`
+
+	rows, err := db.QueryContext(ctx, q, pq.Array(ids), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exceptions := make(map[int64]map[time.Time]*EventException)
+
+	for rows.Next() {
+		var (
+			ex    EventException
+			day   *time.Time
+			clock interface{}
+		)
+
+		err := rows.Scan(
+			&ex.EventId,
+			&ex.OccurrenceDate,
+			&ex.Cancelled,
+			&ex.Title,
+			&ex.Description,
+			&day,
+			&clock,
+			&ex.Status,
+			&ex.Priority,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if day != nil {
+			d := Date{Time: *day}
+			if err := (clockScanner{date: &d}).Scan(clock); err != nil {
+				return nil, err
+			}
+			ex.Date = &d
+		}
+
+		key := time.Date(ex.OccurrenceDate.Year(), ex.OccurrenceDate.Month(), ex.OccurrenceDate.Day(), 0, 0, 0, 0, time.UTC)
+
+		if exceptions[ex.EventId] == nil {
+			exceptions[ex.EventId] = make(map[time.Time]*EventException)
+		}
+		exceptions[ex.EventId][key] = &ex
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exceptions, nil
+}
+
+// SaveException сохраняет изменения одного повторения события пользователя ownerId.
+// Ранее сохранённые изменения полей, не заданных в ex, остаются в силе
+func (e EventModel) SaveException(ex *EventException, ownerId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return saveException(ctx, e.DB, ex, ownerId)
+}
+
+func saveException(ctx context.Context, db querier, ex *EventException, ownerId int64) error {
+	q := `insert into event_exceptions
+			(event_id, occurrence_date, cancelled, title, description, date, due_time, status, priority)
+		select $1, $2, $3, $4, $5, $6, $7, $8, $9
+		where exists (select 1 from events where id = $1 and owner_id = $10 and deleted_at is null)
+		on conflict (event_id, occurrence_date) do update
+		set cancelled   = excluded.cancelled,
+		    title       = coalesce(excluded.title, event_exceptions.title),
+		    description = coalesce(excluded.description, event_exceptions.description),
+		    date        = coalesce(excluded.date, event_exceptions.date),
+		    due_time    = case when excluded.date is null then event_exceptions.due_time else excluded.due_time end,
+		    status      = coalesce(excluded.status, event_exceptions.status),
+		    priority    = coalesce(excluded.priority, event_exceptions.priority)
+		returning event_id`
+
+	var day, clock interface{}
+	if ex.Date != nil {
+		day = ex.Date.day()
+		clock = ex.Date.clock()
+	}
+
+	args := []interface{}{
+		ex.EventId,
+		ex.OccurrenceDate,
+		ex.Cancelled,
+		ex.Title,
+		ex.Description,
+		day,
+		clock,
+		ex.Status,
+		ex.Priority,
+		ownerId,
+	}
+
+	err := db.QueryRowContext(ctx, q, args...).Scan(&ex.EventId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateEventScope проверяет область изменения события. Для отдельного повторения
+// и для "этого и следующих" occurrence должен быть днём одного из повторений серии
+func ValidateEventScope(v *validation.Validator, event *Event, scope string, occurrence time.Time) {
+	v.Check(validation.In(scope, ScopeAll, ScopeOccurrence, ScopeFollowing), "scope", "invalid scope value")
+
+	if !v.Valid() || scope == ScopeAll {
+		return
+	}
+
+	if occurrence.IsZero() {
+		v.AddError("occurrence", "must be provided")
+		return
+	}
+
+	rule, err := event.Rule()
+	if err != nil || rule == nil {
+		v.AddError("scope", "must be all for events without recurrence")
+		return
+	}
+
+	v.Check(rule.Includes(event.Date.Time, occurrence), "occurrence", "is not an occurrence of the event")
+}
+
+// Split завершает серию перед днём from и возвращает новую серию, начинающуюся
+// с этого дня. Оставшееся количество повторений (COUNT) переходит к новой серии
+func (e *Event) Split(from time.Time) (*Event, error) {
+	rule, err := e.Rule()
+	if err != nil {
+		return nil, err
+	}
+
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+
+	nextRule := *rule
+
+	if rule.Count > 0 {
+		before := rule.CountBefore(e.Date.Time, from)
+		nextRule.Count = rule.Count - before
+		rule.Count = before
+	} else {
+		rule.Until = from.AddDate(0, 0, -1)
+	}
+
+	next := *e
+	next.ID = 0
+	next.Version = 0
+	next.prevStatus = ""
+	next.SeriesId = &e.ID
+	// UID импортированного календаря остаётся у исходной серии
+	next.ICalUID = ""
+	next.Recurrence = nextRule.String()
+	next.Date.Time = time.Date(from.Year(), from.Month(), from.Day(),
+		e.Date.Hour(), e.Date.Minute(), e.Date.Second(), 0, e.Date.Location())
+
+	e.Recurrence = rule.String()
+
+	return &next, nil
+}
+
+// SplitSeries сохраняет серию original, завершённую методом Split, и создаёт
+// серию next с метками nextTagIds, продолжающую её с дня from. Если next == nil, серия только
+// завершается. Изменения повторений исходной серии начиная с from удаляются
+func (e EventModel) SplitSeries(original, next *Event, from time.Time, nextTagIds []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = updateEvent(ctx, tx, original)
+	if err != nil {
+		return err
+	}
+
+	if next != nil {
+		err = insertEvent(ctx, tx, next)
+		if err != nil {
+			return err
+		}
+
+		if len(nextTagIds) > 0 {
+			err = setEventTags(ctx, tx, next, nextTagIds)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	q := `delete from event_exceptions where event_id = $1 and occurrence_date >= $2`
+
+	_, err = tx.ExecContext(ctx, q, original.ID, from)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// maxExpandedEvents ограничивает количество событий, загружаемых для развёртывания повторений
+const maxExpandedEvents = 5000
+
+// getAllExpanded выборка событий, в которой каждая серия заменена её повторениями
+// в диапазоне [f.DateFrom, f.DateTo]. Сортировка и разбиение на страницы
+// выполняются после развёртывания. Если событий больше maxExpandedEvents
+// или у серии больше maxOccurrences повторений, возвращает ErrTooManyEvents
+func (e EventModel) getAllExpanded(ownerId int64, f EventFilters) ([]*Event, Metadata, error) {
+	q := fmt.Sprintf(`
+        select %s, %s
+        from events
+        where %s
+        order by id
+        limit %d`, eventColumns, eventSearchColumns, eventFilterConditions, maxExpandedEvents+1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := e.DB.QueryContext(ctx, q, f.args(ownerId)...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var (
+		events    []*Event
+		seriesIds []int64
+	)
+
+	for rows.Next() {
+		var event Event
+
+		err := rows.Scan(append(event.columns(), &event.Relevance, &event.Headline)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if event.Recurrence != "" {
+			seriesIds = append(seriesIds, event.ID)
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(events) > maxExpandedEvents {
+		return nil, Metadata{}, ErrTooManyEvents
+	}
+
+	exceptions := map[int64]map[time.Time]*EventException{}
+	if len(seriesIds) > 0 {
+		exceptions, err = getExceptions(ctx, e.DB, seriesIds, f.DateFrom.Time, f.DateTo.Time)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	expanded := []*Event{}
+
+	for _, event := range events {
+		rule, err := event.Rule()
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if rule == nil {
+			expanded = append(expanded, event)
+			continue
+		}
+
+		days := rule.Between(event.Date.Time, f.DateFrom.Time, f.DateTo.Time)
+		if len(days) > maxOccurrences {
+			return nil, Metadata{}, ErrTooManyEvents
+		}
+
+		for _, d := range days {
+			occ := event.occurrence(d, exceptions[event.ID][d])
+			if occ == nil {
+				continue
+			}
+
+			if len(f.Statuses) > 0 && !validation.In(occ.Status, f.Statuses...) {
+				continue
+			}
+
+			expanded = append(expanded, occ)
+		}
+	}
+
+	sort.SliceStable(expanded, func(i, j int) bool {
+		return f.less(expanded[i], expanded[j])
+	})
+
+	totalRecords := len(expanded)
+
+	start := min(f.offset(), totalRecords)
+	end := min(start+f.limit(), totalRecords)
+
+	return expanded[start:end], calculateMetadata(totalRecords, f.Page, f.PageSize), nil
+}
+
+// less порядок событий, совпадающий с сортировкой в GetAll. Повторения одной
+// серии дополнительно упорядочиваются по дате
+func (f EventFilters) less(a, b *Event) bool {
+	var c int
+
+	switch f.sortColumn() {
+	case "title":
+		c = strings.Compare(a.Title, b.Title)
+	case "date":
+		c = strings.Compare(a.Date.day(), b.Date.day())
+	case "priority":
+		c = cmp.Compare(a.Priority, b.Priority)
+	case "relevance":
+		c = cmp.Compare(a.Relevance, b.Relevance)
+	case "position":
+		c = cmp.Compare(a.Position, b.Position)
+	default:
+		c = cmp.Compare(a.ID, b.ID)
+	}
+
+	if f.sortDirection() == "DESC" {
+		c = -c
+	}
+
+	if c != 0 {
+		return c < 0
+	}
+
+	if a.ID != b.ID {
+		return a.ID < b.ID
+	}
+
+	return a.Date.Before(b.Date.Time)
+}
@@ -0,0 +1,346 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"library/internal/validation"
+	"time"
+)
+
+// ErrTooManyReminders у события уже maxEventReminders неотправленных напоминаний
+var ErrTooManyReminders = errors.New("too many reminders")
+
+const (
+	// MaxReminderAttempts количество попыток отправить напоминание, после которого оно больше не отправляется
+	MaxReminderAttempts = 5
+	// reminderLease время, на которое напоминание закрепляется за отправителем
+	reminderLease = 5 * time.Minute
+	// maxEventReminders ограничивает количество напоминаний одного события
+	maxEventReminders = 10
+)
+
+// Reminder напоминание о событии. Задаётся либо MinutesBefore - за сколько минут
+// до начала события напомнить, либо At - время в день события в формате "15:04".
+// Для событий без времени началом считается полночь UTC
+type Reminder struct {
+	ID             int64      `json:"id"`
+	EventId        int64      `json:"event_id"`
+	OwnerId        int64      `json:"-"`
+	MinutesBefore  *int       `json:"minutes_before,omitempty"`
+	At             *string    `json:"at,omitempty"`
+	RemindAt       time.Time  `json:"remind_at"`
+	OccurrenceDate *Date      `json:"occurrence_date,omitempty"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+	Attempts       int        `json:"-"`
+	LastError      *string    `json:"last_error,omitempty"`
+}
+
+// DueReminder напоминание, время отправки которого наступило, вместе с адресом владельца
+type DueReminder struct {
+	Reminder
+	Name  string
+	Email string
+}
+
+func ValidateReminder(v *validation.Validator, r *Reminder) {
+	v.Check((r.MinutesBefore == nil) != (r.At == nil), "reminder", "exactly one of minutes_before or at must be provided")
+
+	if r.MinutesBefore != nil {
+		v.Check(*r.MinutesBefore >= 0, "minutes_before", "must not be negative")
+		v.Check(*r.MinutesBefore <= 60*24*365, "minutes_before", "must not be more than a year")
+	}
+
+	if r.At != nil {
+		_, err := time.Parse("15:04", *r.At)
+		v.Check(err == nil, "at", "must be a time in format HH:MM")
+	}
+}
+
+// fireTime время отправки напоминания для события, начинающегося в start
+func (r *Reminder) fireTime(start Date) time.Time {
+	if r.MinutesBefore != nil {
+		return start.Time.Add(-time.Duration(*r.MinutesBefore) * time.Minute)
+	}
+
+	at, _ := time.Parse("15:04", *r.At)
+
+	return time.Date(start.Year(), start.Month(), start.Day(), at.Hour(), at.Minute(), 0, 0, start.Location())
+}
+
+// Schedule вычисляет ближайшее время отправки не раньше now. Для повторяющихся
+// событий выбирается первое подходящее повторение. Возвращает false, если
+// напоминание больше не может быть отправлено
+func (r *Reminder) Schedule(event *Event, now time.Time) bool {
+	rule, err := event.Rule()
+	if err != nil {
+		return false
+	}
+
+	if rule == nil {
+		r.RemindAt = r.fireTime(event.Date)
+		r.OccurrenceDate = nil
+		return !r.RemindAt.Before(now)
+	}
+
+	// Для напоминаний "за N минут" повторение не может начинаться раньше now + N минут
+	after := now
+	if r.MinutesBefore != nil {
+		after = now.Add(time.Duration(*r.MinutesBefore) * time.Minute)
+	}
+
+	for i := 0; i < maxOccurrences; i++ {
+		d, ok := rule.Next(event.Date.Time, after)
+		if !ok {
+			return false
+		}
+
+		start := event.Date
+		start.Time = time.Date(d.Year(), d.Month(), d.Day(),
+			event.Date.Hour(), event.Date.Minute(), event.Date.Second(), 0, event.Date.Location())
+
+		if fire := r.fireTime(start); !fire.Before(now) {
+			r.RemindAt = fire
+			r.OccurrenceDate = &Date{Time: d}
+			return true
+		}
+
+		after = d.AddDate(0, 0, 1)
+	}
+
+	return false
+}
+
+type ReminderModel struct {
+	DB *sql.DB
+}
+
+const reminderColumns = `id, event_id, owner_id, minutes_before, to_char(at_time, 'HH24:MI'),
+		remind_at, occurrence_date, sent_at, attempts, last_error`
+
+func (r *Reminder) columns() []interface{} {
+	return []interface{}{
+		&r.ID,
+		&r.EventId,
+		&r.OwnerId,
+		&r.MinutesBefore,
+		&r.At,
+		&r.RemindAt,
+		nullDateScanner{date: &r.OccurrenceDate},
+		&r.SentAt,
+		&r.Attempts,
+		&r.LastError,
+	}
+}
+
+func (r *Reminder) occurrenceDate() interface{} {
+	if r.OccurrenceDate == nil {
+		return nil
+	}
+
+	return r.OccurrenceDate.day()
+}
+
+// Insert сохраняет напоминание, если у события пользователя ещё не слишком много напоминаний
+func (m ReminderModel) Insert(r *Reminder) error {
+	q := `insert into reminders (event_id, owner_id, minutes_before, at_time, remind_at, occurrence_date)
+		select $1, $2, $3, $4::time, $5, $6
+		where exists (select 1 from events where id = $1 and owner_id = $2 and deleted_at is null)
+		  and (select count(*) from reminders where event_id = $1 and sent_at is null) < $7
+		returning id`
+
+	args := []interface{}{r.EventId, r.OwnerId, r.MinutesBefore, r.At, r.RemindAt, r.occurrenceDate(), maxEventReminders}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, q, args...).Scan(&r.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrTooManyReminders
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetForEvent возвращает напоминания события пользователя ownerId
+func (m ReminderModel) GetForEvent(eventId, ownerId int64) ([]*Reminder, error) {
+	q := `select ` + reminderColumns + `
+		from reminders
+		where event_id = $1 and owner_id = $2
+		order by remind_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.query(ctx, q, eventId, ownerId)
+}
+
+func (m ReminderModel) query(ctx context.Context, q string, args ...interface{}) ([]*Reminder, error) {
+	rows, err := m.DB.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reminders := []*Reminder{}
+
+	for rows.Next() {
+		var r Reminder
+
+		err := rows.Scan(r.columns()...)
+		if err != nil {
+			return nil, err
+		}
+
+		reminders = append(reminders, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+func (m ReminderModel) Delete(id, eventId, ownerId int64) error {
+	q := `delete from reminders where id = $1 and event_id = $2 and owner_id = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, q, id, eventId, ownerId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Reschedule пересчитывает время отправки неотправленных напоминаний события
+// после его изменения. Напоминания, время которых уже прошло, удаляются
+func (m ReminderModel) Reschedule(event *Event) error {
+	q := `select ` + reminderColumns + `
+		from reminders
+		where event_id = $1 and sent_at is null`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	reminders, err := m.query(ctx, q, event.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, r := range reminders {
+		if !r.Schedule(event, now) {
+			_, err = m.DB.ExecContext(ctx, `delete from reminders where id = $1`, r.ID)
+		} else {
+			_, err = m.DB.ExecContext(ctx,
+				`update reminders set remind_at = $2, occurrence_date = $3, attempts = 0, last_error = null where id = $1`,
+				r.ID, r.RemindAt, r.occurrenceDate())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ClaimDue закрепляет за вызывающим до limit напоминаний, время которых наступило.
+// Закреплённые напоминания не выдаются другим отправителям, пока не истечёт
+// reminderLease, поэтому после сбоя отправки они будут выданы снова
+func (m ReminderModel) ClaimDue(limit int) ([]*DueReminder, error) {
+	q := `update reminders r
+		set locked_until = now() + $2 * interval '1 second', attempts = attempts + 1
+		from users u
+		where u.id = r.owner_id and r.id in (
+			select id from reminders
+			where sent_at is null and remind_at <= now() and attempts < $3
+			  and (locked_until is null or locked_until < now())
+			  and not exists (select 1 from events e where e.id = event_id and e.deleted_at is not null)
+			order by remind_at
+			limit $1
+			for update skip locked)
+		returning r.id, r.event_id, r.owner_id, r.minutes_before, to_char(r.at_time, 'HH24:MI'),
+			r.remind_at, r.occurrence_date, r.sent_at, r.attempts, r.last_error, u.name, u.email`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, q, limit, reminderLease.Seconds(), MaxReminderAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []*DueReminder
+
+	for rows.Next() {
+		var r DueReminder
+
+		err := rows.Scan(append(r.columns(), &r.Name, &r.Email)...)
+		if err != nil {
+			return nil, err
+		}
+
+		due = append(due, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return due, nil
+}
+
+// Complete отмечает напоминание отправленным. Если событие повторяется,
+// напоминание переносится на следующее повторение
+func (m ReminderModel) Complete(r *Reminder, event *Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	sent := r.RemindAt
+
+	if event != nil && event.Recurrence != "" && r.Schedule(event, sent.Add(time.Second)) {
+		q := `update reminders
+			set remind_at = $2, occurrence_date = $3, attempts = 0, locked_until = null, last_error = null
+			where id = $1`
+
+		_, err := m.DB.ExecContext(ctx, q, r.ID, r.RemindAt, r.occurrenceDate())
+		return err
+	}
+
+	q := `update reminders set sent_at = now(), locked_until = null, last_error = null where id = $1`
+
+	_, err := m.DB.ExecContext(ctx, q, r.ID)
+	return err
+}
+
+// Fail сохраняет ошибку отправки. Напоминание будет отправлено повторно
+// по истечении reminderLease, если не исчерпаны попытки
+func (m ReminderModel) Fail(r *Reminder, sendErr error) error {
+	q := `update reminders set last_error = $2 where id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, q, r.ID, sendErr.Error())
+	return err
+}
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Revision сохранённая версия события. Версии записывает триггер
+// events_revision_update_trigger при каждом увеличении version
+type Revision struct {
+	Version   int64     `json:"version"`
+	UserId    *int64    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Changed поля, изменившиеся по сравнению с предыдущей версией
+	Changed []string      `json:"changed"`
+	State   RevisionState `json:"event"`
+}
+
+// RevisionState состояние события в одной из версий
+type RevisionState struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	TextBlocks  Blocks   `json:"text_blocks"`
+	Date        Date     `json:"date"`
+	CardId      int64    `json:"card_id"`
+	Status      string   `json:"status"`
+	Priority    Priority `json:"priority"`
+	Recurrence  string   `json:"recurrence"`
+	Position    float64  `json:"position"`
+}
+
+// Scan читает снимок, построенный функцией events_revision_snapshot
+func (s *RevisionState) Scan(src any) error {
+	var js []byte
+
+	switch v := src.(type) {
+	case []byte:
+		js = v
+	case string:
+		js = []byte(v)
+	default:
+		return fmt.Errorf("unsupported snapshot type %T", src)
+	}
+
+	var snapshot struct {
+		Title       string  `json:"title"`
+		Description string  `json:"description"`
+		TextBlocks  Blocks  `json:"text_blocks"`
+		Date        string  `json:"date"`
+		DueTime     *string `json:"due_time"`
+		CardId      int64   `json:"card_id"`
+		Status      string  `json:"status"`
+		Priority    int16   `json:"priority"`
+		Recurrence  *string `json:"recurrence"`
+		Position    float64 `json:"position"`
+	}
+
+	err := json.Unmarshal(js, &snapshot)
+	if err != nil {
+		return err
+	}
+
+	day, err := time.Parse(layout, snapshot.Date)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot date %q", snapshot.Date)
+	}
+
+	*s = RevisionState{
+		Title:       snapshot.Title,
+		Description: snapshot.Description,
+		TextBlocks:  snapshot.TextBlocks,
+		Date:        Date{Time: day},
+		CardId:      snapshot.CardId,
+		Status:      snapshot.Status,
+		Priority:    Priority(snapshot.Priority),
+		Position:    snapshot.Position,
+	}
+
+	if snapshot.Recurrence != nil {
+		s.Recurrence = *snapshot.Recurrence
+	}
+
+	if snapshot.DueTime != nil {
+		return clockScanner{date: &s.Date}.Scan(*snapshot.DueTime)
+	}
+
+	return nil
+}
+
+// ApplyTo возвращает событию состояние версии. Позиция не восстанавливается:
+// порядок событий в карточке с тех пор мог измениться
+func (s RevisionState) ApplyTo(event *Event) {
+	event.Title = s.Title
+	event.Description = s.Description
+	event.TextBlocks = s.TextBlocks
+	event.Date = s.Date
+	event.CardId = s.CardId
+	event.Status = s.Status
+	event.Priority = s.Priority
+	event.Recurrence = s.Recurrence
+}
+
+// FieldChange значения поля в двух сравниваемых версиях
+type FieldChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// Diff возвращает поля, значения которых в версиях from и to различаются
+func Diff(from, to *Revision) (map[string]FieldChange, error) {
+	fromFields, err := revisionFields(from.State)
+	if err != nil {
+		return nil, err
+	}
+
+	toFields, err := revisionFields(to.State)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]FieldChange)
+
+	for name, value := range toFields {
+		if !bytes.Equal(fromFields[name], value) {
+			changes[name] = FieldChange{From: fromFields[name], To: value}
+		}
+	}
+
+	return changes, nil
+}
+
+// revisionFields значения полей состояния в том виде, в каком они передаются в API
+func revisionFields(s RevisionState) (map[string]json.RawMessage, error) {
+	js, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+
+	err = json.Unmarshal(js, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+type RevisionModel struct {
+	DB *sql.DB
+}
+
+const revisionColumns = `version, user_id, created_at, changed, snapshot`
+
+func (r *Revision) columns() []interface{} {
+	return []interface{}{
+		&r.Version,
+		&r.UserId,
+		&r.CreatedAt,
+		pq.Array(&r.Changed),
+		&r.State,
+	}
+}
+
+// GetAll возвращает версии события, по умолчанию начиная с последней
+func (m RevisionModel) GetAll(eventId int64, f Filters) ([]*Revision, Metadata, error) {
+	q := fmt.Sprintf(`
+		select count(*) over(), %s
+		from event_revisions
+		where event_id = $1
+		order by %s %s
+		limit $2 offset $3`, revisionColumns, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, q, eventId, f.limit(), f.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*Revision{}
+
+	for rows.Next() {
+		var revision Revision
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, revision.columns()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return revisions, calculateMetadata(totalRecords, f.Page, f.PageSize), nil
+}
+
+func (m RevisionModel) Get(eventId, version int64) (*Revision, error) {
+	if version < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	q := `select ` + revisionColumns + `
+		from event_revisions
+		where event_id = $1 and version = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var revision Revision
+
+	err := m.DB.QueryRowContext(ctx, q, eventId, version).Scan(revision.columns()...)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
@@ -3,30 +3,181 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"library/internal/validation"
 	"time"
 )
 
-// TODO: реализовать role-based access control
 const (
 	AdminRole = "admin"
 	UserRole  = "user"
 	GuestRole = "guest"
 )
 
+const roleUniqueConstraintName = "roles_role_key"
+
+// builtinRoles роли, на которые опирается само приложение: user выдаётся при регистрации,
+// admin открывает доступ к управлению ролями. Их нельзя удалить
+var builtinRoles = Roles{AdminRole, UserRole}
+
+// builtinPermissions права встроенных ролей, которые нельзя отозвать
+var builtinPermissions = map[string]Permissions{
+	AdminRole: {"admin:access"},
+}
+
+var (
+	ErrDuplicateRole = errors.New("duplicate role")
+	ErrBuiltinRole   = errors.New("built-in role cannot be deleted or lose its required permissions")
+)
+
 type RoleModel struct {
 	DB *sql.DB
 }
 
+type Role struct {
+	ID          int64       `json:"id"`
+	Role        string      `json:"role"`
+	Permissions Permissions `json:"permissions"`
+}
+
 type Roles []string
 
-// TODO: getUserRole
+func (r Roles) Include(role string) bool {
+	return validation.In(role, r...)
+}
+
+func ValidateRole(v *validation.Validator, role *Role) {
+	v.Check(role.Role != "", "role", "must be provided")
+	v.Check(len(role.Role) <= 100, "role", "must not be more than 100 bytes long")
+}
+
+// ValidateRoleNames проверяет, что все переданные роли существуют
+func ValidateRoleNames(v *validation.Validator, roles []string, known Roles) {
+	v.Check(len(roles) >= 1, "roles", "must contain at least 1 element")
+	v.Check(validation.Unique(roles), "roles", "must not contain duplicate values")
+
+	for _, role := range roles {
+		v.Check(known.Include(role), "roles", "contains unknown role "+role)
+	}
+}
+
+func (r RoleModel) GetAll() ([]*Role, error) {
+	q := `select roles.id,
+			   roles.role,
+			   coalesce(
+					   array_agg(permissions.permission order by permissions.permission)
+					   filter ( where permissions.id is not null ),
+					   '{}'
+			   ) as permissions
+		from roles
+				 left join roles_permissions on roles.id = roles_permissions.role_id
+				 left join permissions on roles_permissions.permission_id = permissions.id
+		group by roles.id, roles.role
+		order by roles.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*Role{}
+
+	for rows.Next() {
+		var role Role
+
+		err := rows.Scan(&role.ID, &role.Role, pq.Array((*[]string)(&role.Permissions)))
+		if err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, &role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (r RoleModel) Get(id int64) (*Role, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	q := `select roles.id,
+			   roles.role,
+			   coalesce(
+					   array_agg(permissions.permission order by permissions.permission)
+					   filter ( where permissions.id is not null ),
+					   '{}'
+			   ) as permissions
+		from roles
+				 left join roles_permissions on roles.id = roles_permissions.role_id
+				 left join permissions on roles_permissions.permission_id = permissions.id
+		where roles.id = $1
+		group by roles.id, roles.role`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var role Role
+
+	err := r.DB.QueryRowContext(ctx, q, id).Scan(&role.ID, &role.Role, pq.Array((*[]string)(&role.Permissions)))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &role, nil
+}
+
+// Names возвращает названия всех существующих ролей
+func (r RoleModel) Names() (Roles, error) {
+	q := `select role from roles order by id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := r.DB.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles Roles
+
+	for rows.Next() {
+		var role string
+		err := rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
 
 func (r RoleModel) GetUserRoles(userId int64) (Roles, error) {
 
 	q := `select role from roles
 		inner join users_roles on roles.id = users_roles.role_id
-		inner join users on users_roles.user_id = users.id
-		where users.id = $1`
+		where users_roles.user_id = $1
+		order by roles.id`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -35,8 +186,9 @@ func (r RoleModel) GetUserRoles(userId int64) (Roles, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	var roles Roles
+	roles := Roles{}
 
 	for rows.Next() {
 		var role string
@@ -54,31 +206,153 @@ func (r RoleModel) GetUserRoles(userId int64) (Roles, error) {
 	return roles, nil
 }
 
-// TODO: setUserRole
+func (r RoleModel) AddUserRoles(userId int64, roles ...string) error {
+	q := `insert into users_roles (user_id, role_id)
+		select $1, roles.id from roles where roles.role = any($2)
+		on conflict do nothing`
 
-func (r RoleModel) AddPermissionToRole(roleId, permissionId int64) error {
-	q := `insert into roles_permissions (role_id, permission_id)
-        values ($1, $2)`
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := r.DB.ExecContext(ctx, q, userId, pq.Array(roles))
+	return err
+}
+
+func (r RoleModel) RemoveUserRoles(userId int64, roles ...string) error {
+	q := `delete from users_roles
+		where user_id = $1
+		and role_id in (select id from roles where role = any($2))`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := r.DB.ExecContext(ctx, q, roleId, permissionId)
+	_, err := r.DB.ExecContext(ctx, q, userId, pq.Array(roles))
 	return err
 }
 
-// TODO: remove permissions from role
+func (r RoleModel) AddPermissionsToRole(roleId int64, permissions ...string) error {
+	q := `insert into roles_permissions (role_id, permission_id)
+		select $1, p.id from permissions as p where p.permission = any($2)
+		on conflict do nothing`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-func (r RoleModel) AddRole(role string) error {
-	q := `insert into roles (role) values ($1)`
+	_, err := r.DB.ExecContext(ctx, q, roleId, pq.Array(permissions))
+	return err
+}
 
+// RemovePermissionsFromRole отзывает права у роли. Обязательные права встроенной роли
+// отозвать нельзя, в этом случае возвращается ErrBuiltinRole
+func (r RoleModel) RemovePermissionsFromRole(roleId int64, permissions ...string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := r.DB.ExecContext(ctx, q, role)
+	role, err := r.name(ctx, roleId)
+	if err != nil {
+		return err
+	}
+
+	for _, permission := range permissions {
+		if builtinPermissions[role].Include(permission) {
+			return ErrBuiltinRole
+		}
+	}
+
+	q := `delete from roles_permissions
+		where role_id = $1
+		and permission_id in (select id from permissions where permission = any($2))`
+
+	_, err = r.DB.ExecContext(ctx, q, roleId, pq.Array(permissions))
 	return err
 }
 
-func (r RoleModel) hasPermission(role, permission string) (bool, error) {
-	return true, nil
+func (r RoleModel) AddRole(role *Role) error {
+	q := `insert into roles (role) values ($1) returning id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := r.DB.QueryRowContext(ctx, q, role.Role).Scan(&role.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Constraint == roleUniqueConstraintName:
+			return ErrDuplicateRole
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteRole удаляет роль. Встроенные роли не удаляются, для них возвращается ErrBuiltinRole
+func (r RoleModel) DeleteRole(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	role, err := r.name(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if builtinRoles.Include(role) {
+		return ErrBuiltinRole
+	}
+
+	q := `delete from roles where id = $1`
+
+	res, err := r.DB.ExecContext(ctx, q, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// name возвращает название роли id
+func (r RoleModel) name(ctx context.Context, id int64) (string, error) {
+	var role string
+
+	err := r.DB.QueryRowContext(ctx, `select role from roles where id = $1`, id).Scan(&role)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return role, nil
+}
+
+func (r RoleModel) HasPermission(role, permission string) (bool, error) {
+	q := `select exists(
+			select 1 from roles
+			inner join roles_permissions on roles.id = roles_permissions.role_id
+			inner join permissions on roles_permissions.permission_id = permissions.id
+			where roles.role = $1 and permissions.permission = $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var ok bool
+
+	err := r.DB.QueryRowContext(ctx, q, role, permission).Scan(&ok)
+	return ok, err
 }
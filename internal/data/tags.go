@@ -0,0 +1,305 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"library/internal/validation"
+	"regexp"
+	"time"
+)
+
+const tagNameUniqueConstraintName = "tags_owner_name_key"
+
+// Режимы фильтрации событий по меткам
+const (
+	// TagModeAny событие должно иметь хотя бы одну из меток
+	TagModeAny = "any"
+	// TagModeAll событие должно иметь все метки
+	TagModeAll = "all"
+)
+
+var (
+	ErrDuplicateTag = errors.New("duplicate tag")
+	ErrTagNotFound  = errors.New("tag not found")
+)
+
+var ColorRX = regexp.MustCompile("^#[0-9a-fA-F]{6}$")
+
+type Tag struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"-"`
+	OwnerId   int64     `json:"-"`
+}
+
+// Tags метки события. Считываются из json-массива, который собирает eventColumns
+type Tags []*Tag
+
+func (t *Tags) Scan(src any) error {
+	var js []byte
+
+	switch v := src.(type) {
+	case nil:
+		*t = Tags{}
+		return nil
+	case []byte:
+		js = v
+	case string:
+		js = []byte(v)
+	default:
+		return fmt.Errorf("unsupported tags type %T", src)
+	}
+
+	*t = Tags{}
+
+	return json.Unmarshal(js, t)
+}
+
+func ValidateTag(v *validation.Validator, tag *Tag) {
+	v.Check(tag.Name != "", "name", "must be provided")
+	v.Check(len(tag.Name) <= 50, "name", "must not be more than 50 bytes long")
+	v.Check(validation.Matches(tag.Color, ColorRX), "color", "must be a hex color like #1a2b3c")
+}
+
+func ValidateTagIds(v *validation.Validator, ids []int64) {
+	v.Check(len(ids) <= 20, "tag_ids", "must not contain more than 20 elements")
+
+	for _, id := range ids {
+		v.Check(id > 0, "tag_ids", "must contain only positive ids")
+	}
+}
+
+type TagModel struct {
+	DB *sql.DB
+}
+
+func (m TagModel) GetAll(ownerId int64) ([]*Tag, error) {
+	q := `select id, name, color, created_at, owner_id
+		from tags
+		where owner_id = $1
+		order by name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, q, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+
+	for rows.Next() {
+		var tag Tag
+
+		err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.OwnerId)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, &tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+func (m TagModel) Get(id, ownerId int64) (*Tag, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	q := `select id, name, color, created_at, owner_id
+		from tags
+		where id = $1 and owner_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var tag Tag
+
+	err := m.DB.QueryRowContext(ctx, q, id, ownerId).Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedAt, &tag.OwnerId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tag, nil
+}
+
+func (m TagModel) Insert(tag *Tag) error {
+	q := `insert into tags (name, color, owner_id)
+		values ($1, $2, $3)
+		returning id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, q, tag.Name, tag.Color, tag.OwnerId).Scan(&tag.ID, &tag.CreatedAt)
+	if err != nil {
+		var pgErr *pq.Error
+		switch {
+		case errors.As(err, &pgErr) && pgErr.Constraint == tagNameUniqueConstraintName:
+			return ErrDuplicateTag
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m TagModel) Update(tag *Tag) error {
+	q := `update tags
+		set name = $1, color = $2
+		where id = $3 and owner_id = $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, q, tag.Name, tag.Color, tag.ID, tag.OwnerId)
+	if err != nil {
+		var pgErr *pq.Error
+		switch {
+		case errors.As(err, &pgErr) && pgErr.Constraint == tagNameUniqueConstraintName:
+			return ErrDuplicateTag
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+// Delete удаляет метку, связи с событиями удаляются вместе с ней
+func (m TagModel) Delete(id, ownerId int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	q := `delete from tags where id = $1 and owner_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, q, id, ownerId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Owned проверяет, что все метки ids принадлежат пользователю ownerId
+func (m TagModel) Owned(ids []int64, ownerId int64) (bool, error) {
+	if len(ids) == 0 {
+		return true, nil
+	}
+
+	q := `select count(*) = cardinality($1::bigint[])
+		from tags
+		where id = any($1) and owner_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var owned bool
+
+	err := m.DB.QueryRowContext(ctx, q, pq.Array(uniqueIds(ids)), ownerId).Scan(&owned)
+	return owned, err
+}
+
+// setEventTags заменяет метки события event на ids и загружает их в event.Tags
+func setEventTags(ctx context.Context, db querier, event *Event, ids []int64) error {
+	_, err := db.ExecContext(ctx, `delete from events_tags where event_id = $1`, event.ID)
+	if err != nil {
+		return err
+	}
+
+	ids = uniqueIds(ids)
+
+	q := `insert into events_tags (event_id, tag_id)
+		select $1, id from tags where id = any($2) and owner_id = $3`
+
+	res, err := db.ExecContext(ctx, q, event.ID, pq.Array(ids), event.OwnerId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != int64(len(ids)) {
+		return ErrTagNotFound
+	}
+
+	q = `select ` + eventTagsColumn + ` from events where id = $1`
+
+	return db.QueryRowContext(ctx, q, event.ID).Scan(&event.Tags)
+}
+
+// eventTagsColumn метки события в виде json-массива, упорядоченного по названию
+const eventTagsColumn = `coalesce((select json_agg(json_build_object('id', t.id, 'name', t.name, 'color', t.color) order by t.name)
+		from events_tags et join tags t on t.id = et.tag_id
+		where et.event_id = events.id), '[]')`
+
+func uniqueIds(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	return unique
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+
+	return unique
+}
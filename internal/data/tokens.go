@@ -13,7 +13,11 @@ import (
 const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
-	TokenDuration       = 24 * time.Hour
+	// ScopeFeed токен календарной подписки, передаётся в адресе, а не в заголовке
+	// Authorization, и даёт только чтение календаря
+	ScopeFeed         = "feed"
+	TokenDuration     = 24 * time.Hour
+	FeedTokenDuration = 365 * 24 * time.Hour
 )
 
 type Token struct {
@@ -0,0 +1,160 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// GetTrash возвращает события пользователя, находящиеся в корзине
+func (e EventModel) GetTrash(ownerId int64, f Filters) ([]*Event, Metadata, error) {
+	q := fmt.Sprintf(`
+        select count(*) over(), %s
+        from events
+        where owner_id = $1 and deleted_at is not null
+        order by %s %s, id ASC
+        limit $2 offset $3`, eventColumns, f.sortColumn(), f.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := e.DB.QueryContext(ctx, q, ownerId, f.limit(), f.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*Event{}
+
+	for rows.Next() {
+		var event Event
+
+		err := rows.Scan(append([]interface{}{&totalRecords}, event.columns()...)...)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return events, calculateMetadata(totalRecords, f.Page, f.PageSize), nil
+}
+
+// Restore возвращает событие из корзины на прежнее место, увеличивая его версию.
+// Возвращает ErrDuplicateTitle, если название за это время заняло другое событие,
+// и ErrDuplicateUID, если тот же календарь за это время импортирован повторно
+func (e EventModel) Restore(id, ownerId int64) (*Event, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	q := `update events
+		set deleted_at = null, version = version + 1
+		where id = $1 and owner_id = $2 and deleted_at is not null`
+
+	res, err := tx.ExecContext(ctx, q, id, ownerId)
+	if err != nil {
+		var pgErr *pq.Error
+		switch {
+		case errors.As(err, &pgErr) && pgErr.Constraint == titleUniqueConstraintName:
+			return nil, ErrDuplicateTitle
+		case errors.As(err, &pgErr) && pgErr.Constraint == icalUIDUniqueConstraintName:
+			return nil, ErrDuplicateUID
+		default:
+			return nil, err
+		}
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	event, err := getEvent(ctx, tx, id, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, tx.Commit()
+}
+
+// Purge окончательно удаляет событие из корзины
+func (e EventModel) Purge(id, ownerId int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	q := `delete from events
+		where id = $1 and owner_id = $2 and deleted_at is not null`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := e.DB.ExecContext(ctx, q, id, ownerId)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// EmptyTrash окончательно удаляет все события пользователя из корзины
+// и возвращает их количество
+func (e EventModel) EmptyTrash(ownerId int64) (int64, error) {
+	q := `delete from events where owner_id = $1 and deleted_at is not null`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	res, err := e.DB.ExecContext(ctx, q, ownerId)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// PurgeTrash окончательно удаляет события всех пользователей, находящиеся
+// в корзине дольше retention, и возвращает их количество
+func (e EventModel) PurgeTrash(retention time.Duration) (int64, error) {
+	q := `delete from events where deleted_at < now() - $1 * interval '1 second'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	res, err := e.DB.ExecContext(ctx, q, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -223,6 +223,18 @@ func (u UserModel) GetForToken(scope, tokenPlainText string) (*User, error) {
 	return &user, nil
 }
 
+func (u UserModel) Exists(id int64) (bool, error) {
+	q := `select exists(select 1 from users where id = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+
+	err := u.DB.QueryRowContext(ctx, q, id).Scan(&exists)
+	return exists, err
+}
+
 func (u UserModel) SetRole(role string, userId int64) error {
 	// TODO: optimize query (using with clause)
 	q := `insert into users_roles
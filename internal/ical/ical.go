@@ -0,0 +1,187 @@
+// Package ical записывает данные в формате iCalendar (RFC 5545)
+package ical
+
+import (
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineLen максимальная длина строки содержимого в октетах без CRLF
+const maxLineLen = 75
+
+// Param параметр свойства, например VALUE=DATE
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Writer записывает компоненты и свойства iCalendar, складывая длинные строки.
+// Первая ошибка записи сохраняется и возвращается Err, последующие записи пропускаются
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Begin открывает компонент, например VCALENDAR или VEVENT
+func (w *Writer) Begin(component string) {
+	w.Property("BEGIN", component)
+}
+
+// End закрывает компонент
+func (w *Writer) End(component string) {
+	w.Property("END", component)
+}
+
+// Property записывает свойство со значением value как есть.
+// Значения типа TEXT нужно записывать через Text
+func (w *Writer) Property(name, value string, params ...Param) {
+	var b strings.Builder
+
+	b.WriteString(name)
+
+	for _, p := range params {
+		b.WriteByte(';')
+		b.WriteString(p.Name)
+		b.WriteByte('=')
+		b.WriteString(paramValue(p.Value))
+	}
+
+	b.WriteByte(':')
+	b.WriteString(value)
+
+	w.writeLine(b.String())
+}
+
+// Text записывает свойство со значением типа TEXT, экранируя его
+func (w *Writer) Text(name, value string, params ...Param) {
+	w.Property(name, EscapeText(value), params...)
+}
+
+// TextList записывает свойство со списком значений типа TEXT, например CATEGORIES
+func (w *Writer) TextList(name string, values []string, params ...Param) {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = EscapeText(v)
+	}
+
+	w.Property(name, strings.Join(escaped, ","), params...)
+}
+
+// Timezone записывает VTIMEZONE для часового пояса с постоянным смещением t
+func (w *Writer) Timezone(t time.Time) {
+	offset := t.Format("-0700")
+
+	w.Begin("VTIMEZONE")
+	w.Property("TZID", OffsetTZID(t))
+	w.Begin("STANDARD")
+	w.Property("DTSTART", "19700101T000000")
+	w.Property("TZOFFSETFROM", offset)
+	w.Property("TZOFFSETTO", offset)
+	w.End("STANDARD")
+	w.End("VTIMEZONE")
+}
+
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// writeLine записывает строку содержимого, перенося её части длиннее maxLineLen
+// на строки продолжения, начинающиеся с пробела. Многобайтовые символы не разрываются
+func (w *Writer) writeLine(line string) {
+	if w.err != nil {
+		return
+	}
+
+	var b strings.Builder
+
+	limit := maxLineLen
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+
+		line = line[cut:]
+		// Пробел в начале строки продолжения занимает один октет
+		limit = maxLineLen - 1
+	}
+
+	b.WriteString(line)
+	b.WriteString("\r\n")
+
+	_, w.err = io.WriteString(w.w, b.String())
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// EscapeText экранирует значение типа TEXT
+func EscapeText(s string) string {
+	return textEscaper.Replace(stripControls(s))
+}
+
+// stripControls удаляет управляющие символы, кроме переводов строки и табуляции,
+// которые запрещены в значениях
+func stripControls(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return r
+		}
+
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+
+		return r
+	}, s)
+}
+
+// paramValue заключает значение параметра в кавычки, если оно содержит ";", ":" или ",".
+// Кавычки внутри значения не допускаются и удаляются
+func paramValue(s string) string {
+	s = strings.ReplaceAll(stripControls(s), `"`, "")
+	s = strings.NewReplacer("\r", "", "\n", " ", "\t", " ").Replace(s)
+
+	if strings.ContainsAny(s, ";:,") {
+		return `"` + s + `"`
+	}
+
+	return s
+}
+
+// FormatDate форматирует значение типа DATE
+func FormatDate(t time.Time) string {
+	return t.Format("20060102")
+}
+
+// FormatDateTime форматирует значение типа DATE-TIME в UTC
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// FormatLocalDateTime форматирует значение типа DATE-TIME в часовом поясе t и возвращает
+// параметр TZID для него. Описание пояса в календаре записывает Writer.Timezone
+func FormatLocalDateTime(t time.Time) (string, Param) {
+	return t.Format("20060102T150405"), Param{Name: "TZID", Value: OffsetTZID(t)}
+}
+
+// OffsetTZID идентификатор часового пояса с постоянным смещением t, например UTC+03:00
+func OffsetTZID(t time.Time) string {
+	return "UTC" + t.Format("-07:00")
+}
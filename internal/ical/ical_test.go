@@ -0,0 +1,179 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"a,b", `a\,b`},
+		{"a;b", `a\;b`},
+		{`a\b`, `a\\b`},
+		{"a\nb", `a\nb`},
+		{"a\r\nb", `a\nb`},
+		{"a\rb", `a\nb`},
+		{"a\x00b\x7f", "ab"},
+		{"tab\tkept", "tab\tkept"},
+	}
+
+	for _, tt := range tests {
+		if got := EscapeText(tt.in); got != tt.want {
+			t.Errorf("EscapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriterFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"short", "Short summary"},
+		{"ascii", strings.Repeat("abcdefghij", 20)},
+		{"cyrillic", strings.Repeat("Привет, мир! ", 20)},
+		{"emoji", strings.Repeat("🎉", 60)},
+		{"exactly one line", strings.Repeat("x", maxLineLen-len("SUMMARY:"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+
+			w := NewWriter(&b)
+			w.Text("SUMMARY", tt.value)
+
+			if err := w.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			out := b.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output %q does not end with CRLF", out)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+
+			for i, line := range lines {
+				if len(line) > maxLineLen {
+					t.Errorf("line %d is %d octets long, want at most %d", i, len(line), maxLineLen)
+				}
+
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a multibyte character: %q", i, line)
+				}
+
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d does not start with a space: %q", i, line)
+				}
+			}
+
+			unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", "")
+			if want := "SUMMARY:" + EscapeText(tt.value); unfolded != want {
+				t.Errorf("unfolded line = %q, want %q", unfolded, want)
+			}
+		})
+	}
+}
+
+func TestWriterParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		param Param
+		want  string
+	}{
+		{"plain", Param{Name: "VALUE", Value: "DATE"}, "DTSTART;VALUE=DATE:20240101\r\n"},
+		{"colon is quoted", Param{Name: "TZID", Value: "Etc/GMT+3:x"}, "DTSTART;TZID=\"Etc/GMT+3:x\":20240101\r\n"},
+		{"comma is quoted", Param{Name: "CN", Value: "Doe, John"}, "DTSTART;CN=\"Doe, John\":20240101\r\n"},
+		{"quotes are removed", Param{Name: "CN", Value: `say "hi"`}, "DTSTART;CN=say hi:20240101\r\n"},
+		{"newline becomes space", Param{Name: "CN", Value: "a\nb"}, "DTSTART;CN=a b:20240101\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+
+			w := NewWriter(&b)
+			w.Property("DTSTART", "20240101", tt.param)
+
+			if got := b.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextList(t *testing.T) {
+	var b strings.Builder
+
+	w := NewWriter(&b)
+	w.TextList("CATEGORIES", []string{"work", "a,b", "c;d"})
+
+	want := `CATEGORIES:work,a\,b,c\;d` + "\r\n"
+	if got := b.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	tm := time.Date(2024, time.March, 1, 10, 30, 0, 0, msk)
+
+	if got := FormatDate(tm); got != "20240301" {
+		t.Errorf("FormatDate() = %q, want 20240301", got)
+	}
+
+	if got := FormatDateTime(tm); got != "20240301T073000Z" {
+		t.Errorf("FormatDateTime() = %q, want 20240301T073000Z", got)
+	}
+
+	value, tz := FormatLocalDateTime(tm)
+	if value != "20240301T103000" || tz != (Param{Name: "TZID", Value: "UTC+03:00"}) {
+		t.Errorf("FormatLocalDateTime() = %q, %v, want 20240301T103000, TZID=UTC+03:00", value, tz)
+	}
+}
+
+func TestOffsetTZID(t *testing.T) {
+	tests := []struct {
+		offset int
+		want   string
+	}{
+		{0, "UTC+00:00"},
+		{3 * 60 * 60, "UTC+03:00"},
+		{-(3*60*60 + 30*60), "UTC-03:30"},
+		{5*60*60 + 45*60, "UTC+05:45"},
+	}
+
+	for _, tt := range tests {
+		tm := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.FixedZone("", tt.offset))
+		if got := OffsetTZID(tm); got != tt.want {
+			t.Errorf("OffsetTZID(%d) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	var b strings.Builder
+
+	w := NewWriter(&b)
+	w.Timezone(time.Date(2024, time.March, 1, 10, 0, 0, 0, time.FixedZone("", -(3*60*60+30*60))))
+
+	want := "BEGIN:VTIMEZONE\r\n" +
+		"TZID:UTC-03:30\r\n" +
+		"BEGIN:STANDARD\r\n" +
+		"DTSTART:19700101T000000\r\n" +
+		"TZOFFSETFROM:-0330\r\n" +
+		"TZOFFSETTO:-0330\r\n" +
+		"END:STANDARD\r\n" +
+		"END:VTIMEZONE\r\n"
+
+	if got := b.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
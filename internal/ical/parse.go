@@ -0,0 +1,319 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCalendar = errors.New("invalid calendar")
+
+// maxDepth ограничивает вложенность компонентов
+const maxDepth = 8
+
+// Component компонент iCalendar, например VCALENDAR или VEVENT
+type Component struct {
+	Name       string
+	Properties []*Property
+	Components []*Component
+}
+
+// Property свойство компонента. Имена свойства и параметров приводятся к верхнему регистру,
+// значения параметров хранятся без кавычек
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Get возвращает первое свойство name или nil
+func (c *Component) Get(name string) *Property {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// All возвращает все свойства name в порядке следования, например все EXDATE
+func (c *Component) All(name string) []*Property {
+	var props []*Property
+
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+
+	return props
+}
+
+// Text возвращает значение свойства name типа TEXT или пустую строку
+func (c *Component) Text(name string) string {
+	p := c.Get(name)
+	if p == nil {
+		return ""
+	}
+
+	return UnescapeText(p.Value)
+}
+
+// Parse читает календарь из r и возвращает его корневой компонент
+func Parse(r io.Reader) (*Component, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var (
+		stack   []*Component
+		root    *Component
+		line    string
+		lineNum int
+		started bool
+	)
+
+	handle := func(line string, n int) error {
+		p, err := parseLine(line)
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrInvalidCalendar, n, err)
+		}
+
+		switch p.Name {
+		case "BEGIN":
+			if root != nil && len(stack) == 0 {
+				return fmt.Errorf("%w: line %d: content after the end of the calendar", ErrInvalidCalendar, n)
+			}
+
+			if len(stack) == maxDepth {
+				return fmt.Errorf("%w: line %d: components are nested too deep", ErrInvalidCalendar, n)
+			}
+
+			c := &Component{Name: strings.ToUpper(p.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			} else {
+				root = c
+			}
+			stack = append(stack, c)
+
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalidCalendar, n, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+
+		default:
+			if len(stack) == 0 {
+				return fmt.Errorf("%w: line %d: property outside of a component", ErrInvalidCalendar, n)
+			}
+
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, p)
+		}
+
+		return nil
+	}
+
+	// Строка, начинающаяся с пробела или табуляции, продолжает предыдущую
+	for scanner.Scan() {
+		lineNum++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if started && len(text) > 0 && (text[0] == ' ' || text[0] == '\t') {
+			line += text[1:]
+			continue
+		}
+
+		if started && line != "" {
+			if err := handle(line, lineNum-1); err != nil {
+				return nil, err
+			}
+		}
+
+		line = text
+		started = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCalendar, err)
+	}
+
+	if line != "" {
+		if err := handle(line, lineNum); err != nil {
+			return nil, err
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("%w: no components found", ErrInvalidCalendar)
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: component %s is not closed", ErrInvalidCalendar, stack[len(stack)-1].Name)
+	}
+
+	return root, nil
+}
+
+// parseLine разбирает строку содержимого вида NAME;PARAM=VALUE:value
+func parseLine(line string) (*Property, error) {
+	p := &Property{Params: map[string]string{}}
+
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, errors.New("missing property name")
+	}
+
+	p.Name = strings.ToUpper(line[:i])
+	rest := line[i:]
+
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid parameter of %s", p.Name)
+		}
+
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value strings.Builder
+
+		// Значение параметра заканчивается на ";" или ":" вне кавычек
+		quoted := false
+		j := 0
+		for ; j < len(rest); j++ {
+			ch := rest[j]
+			if ch == '"' {
+				quoted = !quoted
+				continue
+			}
+			if !quoted && (ch == ';' || ch == ':') {
+				break
+			}
+			value.WriteByte(ch)
+		}
+
+		if quoted {
+			return nil, fmt.Errorf("unterminated quote in parameter %s", name)
+		}
+
+		p.Params[name] = value.String()
+		rest = rest[j:]
+	}
+
+	if !strings.HasPrefix(rest, ":") {
+		return nil, fmt.Errorf("missing value of %s", p.Name)
+	}
+
+	p.Value = rest[1:]
+
+	return p, nil
+}
+
+// UnescapeText возвращает значение типа TEXT без экранирования
+func UnescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
+
+// Times разбирает список значений типа DATE или DATE-TIME через запятую, например EXDATE.
+// Все значения списка имеют общие параметры, в том числе TZID
+func (p *Property) Times() ([]time.Time, bool, error) {
+	var (
+		times  []time.Time
+		allDay bool
+	)
+
+	for _, value := range strings.Split(p.Value, ",") {
+		t, day, err := (&Property{Name: p.Name, Params: p.Params, Value: value}).Time()
+		if err != nil {
+			return nil, false, err
+		}
+
+		times = append(times, t)
+		allDay = day
+	}
+
+	return times, allDay, nil
+}
+
+// Time разбирает значение типа DATE или DATE-TIME. allDay == true для DATE.
+// TZID - имя пояса IANA или пояс с постоянным смещением вида OffsetTZID.
+// Время без часового пояса и с неизвестным TZID считается временем UTC
+func (p *Property) Time() (t time.Time, allDay bool, err error) {
+	value := strings.TrimSpace(p.Value)
+
+	if p.Params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err = time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: invalid date %q", ErrInvalidCalendar, value)
+		}
+
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+	} else {
+		loc := time.UTC
+		if tzid := p.Params["TZID"]; tzid != "" {
+			if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+				loc = l
+			} else if l, ok := offsetLocation(tzid); ok {
+				loc = l
+			}
+		}
+
+		t, err = time.ParseInLocation("20060102T150405", value, loc)
+	}
+
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: invalid date-time %q", ErrInvalidCalendar, value)
+	}
+
+	return t, false, nil
+}
+
+// offsetLocation разбирает идентификатор пояса с постоянным смещением, записанный OffsetTZID
+func offsetLocation(tzid string) (*time.Location, bool) {
+	offset, ok := strings.CutPrefix(tzid, "UTC")
+	if !ok {
+		return nil, false
+	}
+
+	t, err := time.Parse("-07:00", offset)
+	if err != nil {
+		return nil, false
+	}
+
+	_, seconds := t.Zone()
+
+	return time.FixedZone(tzid, seconds), true
+}
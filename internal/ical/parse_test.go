@@ -0,0 +1,302 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnescapeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{`a\,b`, "a,b"},
+		{`a\;b`, "a;b"},
+		{`a\\b`, `a\b`},
+		{`a\nb`, "a\nb"},
+		{`a\Nb`, "a\nb"},
+		{`trailing\`, `trailing\`},
+	}
+
+	for _, tt := range tests {
+		if got := UnescapeText(tt.in); got != tt.want {
+			t.Errorf("UnescapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	tests := []string{
+		"Купить молоко, хлеб; сыр",
+		`C:\path\to\file`,
+		"first line\nsecond line",
+		`already \, escaped`,
+		"",
+	}
+
+	for _, s := range tests {
+		if got := UnescapeText(EscapeText(s)); got != s {
+			t.Errorf("UnescapeText(EscapeText(%q)) = %q", s, got)
+		}
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"ascii", strings.Repeat("abcdefghij", 20)},
+		{"cyrillic", strings.Repeat("Привет, мир! ", 20)},
+		{"emoji", strings.Repeat("🎉", 60)},
+		{"escapes across folds", strings.Repeat(`a,b;c\d`+"\n", 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+
+			w := NewWriter(&b)
+			w.Begin("VCALENDAR")
+			w.Begin("VEVENT")
+			w.Text("SUMMARY", tt.value)
+			w.End("VEVENT")
+			w.End("VCALENDAR")
+
+			root, err := Parse(strings.NewReader(b.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(root.Components) != 1 {
+				t.Fatalf("got %d components, want 1", len(root.Components))
+			}
+
+			if got := root.Components[0].Text("SUMMARY"); got != tt.value {
+				t.Errorf("SUMMARY = %q, want %q", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	src := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"uid:1@example.com\r\n" +
+		"SUMMARY:Long\r\n" +
+		" \tfolded\r\n" +
+		"\tline\r\n" +
+		"DTSTART;TZID=\"Europe/Moscow\";x-param=\"a;b:c\":20240301T100000\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"ACTION:DISPLAY\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	root, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if root.Name != "VCALENDAR" || root.Text("VERSION") != "2.0" {
+		t.Fatalf("root = %s, VERSION = %q", root.Name, root.Text("VERSION"))
+	}
+
+	event := root.Components[0]
+
+	if got := event.Text("UID"); got != "1@example.com" {
+		t.Errorf("UID = %q, property names must be case-insensitive", got)
+	}
+
+	if got := event.Text("SUMMARY"); got != "Long\tfoldedline" {
+		t.Errorf("SUMMARY = %q, want %q", got, "Long\tfoldedline")
+	}
+
+	start := event.Get("DTSTART")
+	if start.Params["TZID"] != "Europe/Moscow" || start.Params["X-PARAM"] != "a;b:c" {
+		t.Errorf("DTSTART params = %v", start.Params)
+	}
+
+	if start.Value != "20240301T100000" {
+		t.Errorf("DTSTART value = %q", start.Value)
+	}
+
+	if len(event.Components) != 1 || event.Components[0].Name != "VALARM" {
+		t.Errorf("nested components = %v", event.Components)
+	}
+
+	if event.Get("DESCRIPTION") != nil || event.Text("DESCRIPTION") != "" {
+		t.Error("missing property must be nil and empty text")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"empty", ""},
+		{"property outside component", "SUMMARY:x\r\n"},
+		{"not closed", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VEVENT\r\n"},
+		{"mismatched end", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{"content after end", "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\nBEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"},
+		{"missing value", "BEGIN:VCALENDAR\r\nSUMMARY\r\nEND:VCALENDAR\r\n"},
+		{"missing name", "BEGIN:VCALENDAR\r\n:x\r\nEND:VCALENDAR\r\n"},
+		{"unterminated quote", "BEGIN:VCALENDAR\r\nX;P=\"a:b\r\nEND:VCALENDAR\r\n"},
+		{"invalid parameter", "BEGIN:VCALENDAR\r\nX;P:b\r\nEND:VCALENDAR\r\n"},
+		{"too deep", strings.Repeat("BEGIN:X\r\n", maxDepth+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.src))
+			if !errors.Is(err, ErrInvalidCalendar) {
+				t.Errorf("Parse() error = %v, want ErrInvalidCalendar", err)
+			}
+		})
+	}
+}
+
+func TestLocalDateTimeRoundTrip(t *testing.T) {
+	monday := time.Date(2024, time.March, 4, 1, 0, 0, 0, time.FixedZone("", 3*60*60))
+
+	var b strings.Builder
+
+	w := NewWriter(&b)
+	w.Begin("VEVENT")
+	value, tz := FormatLocalDateTime(monday)
+	w.Property("DTSTART", value, tz)
+	w.End("VEVENT")
+
+	root, err := Parse(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, allDay, err := root.Get("DTSTART").Time()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Equal(monday) || allDay || got.Weekday() != time.Monday {
+		t.Errorf("Time() = %v (%v), want %v on Monday", got, got.Weekday(), monday)
+	}
+}
+
+func TestPropertyTimes(t *testing.T) {
+	root, err := Parse(strings.NewReader("BEGIN:VEVENT\r\n" +
+		"EXDATE;VALUE=DATE:20240301,20240308\r\n" +
+		"EXDATE;TZID=\"UTC+03:00\":20240315T010000\r\n" +
+		"END:VEVENT\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	props := root.All("EXDATE")
+	if len(props) != 2 {
+		t.Fatalf("All(EXDATE) returned %d properties, want 2", len(props))
+	}
+
+	days, allDay, err := props[0].Times()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Time{
+		time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.March, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	if !allDay || len(days) != 2 || !days[0].Equal(want[0]) || !days[1].Equal(want[1]) {
+		t.Errorf("Times() = %v, %v, want %v, true", days, allDay, want)
+	}
+
+	times, allDay, err := props[1].Times()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if allDay || len(times) != 1 || times[0].Day() != 15 || !times[0].Equal(time.Date(2024, time.March, 14, 22, 0, 0, 0, time.UTC)) {
+		t.Errorf("Times() = %v, %v, want 2024-03-15 01:00 +03:00", times, allDay)
+	}
+
+	if _, _, err := (&Property{Params: map[string]string{}, Value: "20240301,bad"}).Times(); !errors.Is(err, ErrInvalidCalendar) {
+		t.Errorf("Times() error = %v, want ErrInvalidCalendar", err)
+	}
+}
+
+func TestPropertyTime(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skip("time zone database is not available")
+	}
+
+	tests := []struct {
+		name   string
+		prop   Property
+		want   time.Time
+		allDay bool
+	}{
+		{
+			name:   "date",
+			prop:   Property{Params: map[string]string{"VALUE": "DATE"}, Value: "20240301"},
+			want:   time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+			allDay: true,
+		},
+		{
+			name:   "date without value parameter",
+			prop:   Property{Params: map[string]string{}, Value: "20240301"},
+			want:   time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+			allDay: true,
+		},
+		{
+			name: "utc",
+			prop: Property{Params: map[string]string{}, Value: "20240301T100000Z"},
+			want: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "tzid",
+			prop: Property{Params: map[string]string{"TZID": "Europe/Moscow"}, Value: "20240301T100000"},
+			want: time.Date(2024, time.March, 1, 10, 0, 0, 0, moscow),
+		},
+		{
+			name: "fixed offset tzid",
+			prop: Property{Params: map[string]string{"TZID": "UTC-03:30"}, Value: "20240301T100000"},
+			want: time.Date(2024, time.March, 1, 13, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "unknown tzid is utc",
+			prop: Property{Params: map[string]string{"TZID": "Mars/Olympus"}, Value: "20240301T100000"},
+			want: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "floating is utc",
+			prop: Property{Params: map[string]string{}, Value: "20240301T100000"},
+			want: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, allDay, err := tt.prop.Time()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !got.Equal(tt.want) || allDay != tt.allDay {
+				t.Errorf("Time() = %v, %v, want %v, %v", got, allDay, tt.want, tt.allDay)
+			}
+		})
+	}
+
+	for _, value := range []string{"2024-03-01", "20240301T1000", "yesterday"} {
+		p := Property{Params: map[string]string{}, Value: value}
+		if _, _, err := p.Time(); !errors.Is(err, ErrInvalidCalendar) {
+			t.Errorf("Time(%q) error = %v, want ErrInvalidCalendar", value, err)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+// Package rrule реализует подмножество правил повторения RFC 5545:
+// FREQ=DAILY|WEEKLY|MONTHLY|YEARLY, INTERVAL, BYDAY, COUNT и UNTIL.
+// Правила работают с днями, время суток события задаётся отдельно.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods ограничивает перебор периодов для правил, которые
+// редко или никогда не дают повторений (например, 31 число каждого февраля)
+const maxPeriods = 10_000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// WeekdayNum элемент BYDAY. N - порядковый номер дня недели в месяце
+// (1 - первый, -1 - последний), 0 - каждый такой день
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+func (w WeekdayNum) String() string {
+	if w.N == 0 {
+		return weekdayNames[w.Day]
+	}
+
+	return strconv.Itoa(w.N) + weekdayNames[w.Day]
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []WeekdayNum
+	Count    int
+	// Until последний допустимый день повторения включительно, нулевое значение - без ограничения
+	Until time.Time
+}
+
+// Parse разбирает строку вида "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10".
+// Допускается префикс "RRULE:"
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, fmt.Errorf("%w: rule is empty", ErrInvalidRule)
+	}
+
+	r := &Rule{Interval: 1}
+
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+
+		var err error
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			switch r.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return nil, fmt.Errorf("%w: unsupported FREQ %s", ErrInvalidRule, value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive integer", ErrInvalidRule)
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err != nil || r.Count < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be a positive integer", ErrInvalidRule)
+			}
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+			if err != nil {
+				return nil, err
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				return nil, fmt.Errorf("%w: only WKST=MO is supported", ErrInvalidRule)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %s", ErrInvalidRule, key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ must be provided", ErrInvalidRule)
+	}
+
+	if r.Count != 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL must not be used together", ErrInvalidRule)
+	}
+
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != Monthly {
+			return nil, fmt.Errorf("%w: numbered BYDAY is supported only with FREQ=MONTHLY", ErrInvalidRule)
+		}
+	}
+
+	if len(r.ByDay) > 0 && r.Freq == Yearly {
+		return nil, fmt.Errorf("%w: BYDAY is not supported with FREQ=YEARLY", ErrInvalidRule)
+	}
+
+	return r, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, l := range []string{"20060102", "20060102T150405Z", "20060102T150405"} {
+		t, err := time.Parse(l, value)
+		if err == nil {
+			return day(t), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: UNTIL must be a date in format YYYYMMDD", ErrInvalidRule)
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+
+	for _, item := range strings.Split(strings.ToUpper(value), ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("%w: malformed BYDAY value %q", ErrInvalidRule, item)
+		}
+
+		wd, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed BYDAY value %q", ErrInvalidRule, item)
+		}
+
+		var n int
+
+		if num := item[:len(item)-2]; num != "" {
+			var err error
+			n, err = strconv.Atoi(num)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("%w: malformed BYDAY value %q", ErrInvalidRule, item)
+			}
+		}
+
+		days = append(days, WeekdayNum{N: n, Day: wd})
+	}
+
+	return days, nil
+}
+
+// String возвращает правило в каноничном виде
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = d.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Between возвращает дни повторений серии, начинающейся в start,
+// которые попадают в диапазон [from, to] включительно
+func (r Rule) Between(start, from, to time.Time) []time.Time {
+	from, to = day(from), day(to)
+
+	var days []time.Time
+
+	r.each(start, func(d time.Time) bool {
+		if d.After(to) {
+			return false
+		}
+
+		if !d.Before(from) {
+			days = append(days, d)
+		}
+
+		return true
+	})
+
+	return days
+}
+
+// Includes сообщает, приходится ли на день d одно из повторений серии
+func (r Rule) Includes(start, d time.Time) bool {
+	return len(r.Between(start, d, d)) == 1
+}
+
+// CountBefore возвращает количество повторений серии до дня d, не включая его
+func (r Rule) CountBefore(start, d time.Time) int {
+	d = day(d)
+	count := 0
+
+	r.each(start, func(o time.Time) bool {
+		if !o.Before(d) {
+			return false
+		}
+
+		count++
+		return true
+	})
+
+	return count
+}
+
+// Next возвращает первое повторение серии не раньше дня after
+func (r Rule) Next(start, after time.Time) (time.Time, bool) {
+	after = day(after)
+
+	var next time.Time
+
+	r.each(start, func(d time.Time) bool {
+		if d.Before(after) {
+			return true
+		}
+
+		next = d
+		return false
+	})
+
+	return next, !next.IsZero()
+}
+
+// each перебирает дни повторений по возрастанию, пока fn возвращает true.
+// Первым повторением всегда считается сам день start
+func (r Rule) each(start time.Time, fn func(d time.Time) bool) {
+	start = day(start)
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	count := 0
+
+	emit := func(d time.Time) bool {
+		if !r.Until.IsZero() && d.After(r.Until) {
+			return false
+		}
+
+		count++
+		if !fn(d) {
+			return false
+		}
+
+		return r.Count == 0 || count < r.Count
+	}
+
+	if !emit(start) {
+		return
+	}
+
+	for period := 0; period < maxPeriods; period++ {
+		for _, d := range r.period(start, period*interval) {
+			if !d.After(start) {
+				continue
+			}
+
+			if !emit(d) {
+				return
+			}
+		}
+	}
+}
+
+// period возвращает отсортированные дни повторений в периоде,
+// отстоящем от периода start на offset единиц частоты
+func (r Rule) period(start time.Time, offset int) []time.Time {
+	switch r.Freq {
+	case Daily:
+		d := start.AddDate(0, 0, offset)
+		if len(r.ByDay) > 0 && !r.hasWeekday(d.Weekday()) {
+			return nil
+		}
+		return []time.Time{d}
+
+	case Weekly:
+		monday := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*offset)
+		if len(r.ByDay) == 0 {
+			return []time.Time{monday.AddDate(0, 0, (int(start.Weekday())+6)%7)}
+		}
+
+		var days []time.Time
+		for i := 0; i < 7; i++ {
+			d := monday.AddDate(0, 0, i)
+			if r.hasWeekday(d.Weekday()) {
+				days = append(days, d)
+			}
+		}
+		return days
+
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(offset), 1, 0, 0, 0, 0, time.UTC)
+		if len(r.ByDay) == 0 {
+			d := first.AddDate(0, 0, start.Day()-1)
+			if d.Month() != first.Month() {
+				return nil
+			}
+			return []time.Time{d}
+		}
+		return r.monthDays(first)
+
+	case Yearly:
+		d := time.Date(start.Year()+offset, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		if d.Month() != start.Month() {
+			return nil
+		}
+		return []time.Time{d}
+	}
+
+	return nil
+}
+
+// monthDays возвращает дни месяца, соответствующие BYDAY
+func (r Rule) monthDays(first time.Time) []time.Time {
+	last := first.AddDate(0, 1, -1)
+	set := make(map[time.Time]bool)
+
+	for _, wd := range r.ByDay {
+		var matches []time.Time
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			if d.Weekday() == wd.Day {
+				matches = append(matches, d)
+			}
+		}
+
+		switch {
+		case wd.N == 0:
+			for _, d := range matches {
+				set[d] = true
+			}
+		case wd.N > 0 && wd.N <= len(matches):
+			set[matches[wd.N-1]] = true
+		case wd.N < 0 && -wd.N <= len(matches):
+			set[matches[len(matches)+wd.N]] = true
+		}
+	}
+
+	days := make([]time.Time, 0, len(set))
+	for d := range set {
+		days = append(days, d)
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	return days
+}
+
+func (r Rule) hasWeekday(wd time.Weekday) bool {
+	for _, d := range r.ByDay {
+		if d.Day == wd {
+			return true
+		}
+	}
+
+	return false
+}
+
+// day отбрасывает время суток и часовой пояс, оставляя календарный день
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
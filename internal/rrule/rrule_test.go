@@ -0,0 +1,215 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func dates(ss ...string) []time.Time {
+	days := make([]time.Time, len(ss))
+	for i, s := range ss {
+		days[i] = date(s)
+	}
+
+	return days
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want string
+	}{
+		{"daily", "FREQ=DAILY", "FREQ=DAILY"},
+		{"prefix and lower case", "RRULE:freq=weekly;byday=mo,fr", "FREQ=WEEKLY;BYDAY=MO,FR"},
+		{"interval one is omitted", "FREQ=DAILY;INTERVAL=1", "FREQ=DAILY"},
+		{"monthly ordinal", "FREQ=MONTHLY;BYDAY=-1FR;COUNT=4", "FREQ=MONTHLY;BYDAY=-1FR;COUNT=4"},
+		{"until date", "FREQ=DAILY;UNTIL=20240303", "FREQ=DAILY;UNTIL=20240303"},
+		{"until date-time", "FREQ=DAILY;UNTIL=20240303T235959Z", "FREQ=DAILY;UNTIL=20240303"},
+		{"week start monday", "FREQ=WEEKLY;WKST=MO;INTERVAL=2", "FREQ=WEEKLY;INTERVAL=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.rule, err)
+			}
+
+			if got := r.String(); got != tt.want {
+				t.Errorf("Parse(%q).String() = %q, want %q", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"empty", ""},
+		{"missing freq", "INTERVAL=2"},
+		{"unsupported freq", "FREQ=HOURLY"},
+		{"malformed part", "FREQ=DAILY;COUNT"},
+		{"zero interval", "FREQ=DAILY;INTERVAL=0"},
+		{"negative count", "FREQ=DAILY;COUNT=-1"},
+		{"count and until", "FREQ=DAILY;COUNT=2;UNTIL=20240101"},
+		{"invalid until", "FREQ=DAILY;UNTIL=2024-01-01"},
+		{"unknown weekday", "FREQ=WEEKLY;BYDAY=XX"},
+		{"ordinal outside monthly", "FREQ=WEEKLY;BYDAY=1MO"},
+		{"byday with yearly", "FREQ=YEARLY;BYDAY=MO"},
+		{"week start sunday", "FREQ=WEEKLY;WKST=SU"},
+		{"unsupported part", "FREQ=DAILY;BYHOUR=9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.rule)
+			if !errors.Is(err, ErrInvalidRule) {
+				t.Errorf("Parse(%q) error = %v, want ErrInvalidRule", tt.rule, err)
+			}
+		})
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		from  string
+		to    string
+		want  []time.Time
+	}{
+		{
+			name: "last friday of the month", rule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=4",
+			start: "2024-01-26", from: "2024-01-01", to: "2030-01-01",
+			want: dates("2024-01-26", "2024-02-23", "2024-03-29", "2024-04-26"),
+		},
+		{
+			name: "second monday and last friday", rule: "FREQ=MONTHLY;BYDAY=2MO,-1FR",
+			start: "2024-01-08", from: "2024-01-01", to: "2024-02-29",
+			want: dates("2024-01-08", "2024-01-26", "2024-02-12", "2024-02-23"),
+		},
+		{
+			name: "31st skips shorter months", rule: "FREQ=MONTHLY;COUNT=4",
+			start: "2024-01-31", from: "2024-01-01", to: "2030-01-01",
+			want: dates("2024-01-31", "2024-03-31", "2024-05-31", "2024-07-31"),
+		},
+		{
+			name: "february 29 only in leap years", rule: "FREQ=YEARLY;COUNT=3",
+			start: "2024-02-29", from: "2024-01-01", to: "2040-01-01",
+			want: dates("2024-02-29", "2028-02-29", "2032-02-29"),
+		},
+		{
+			name: "count", rule: "FREQ=DAILY;COUNT=3",
+			start: "2024-03-01", from: "2024-01-01", to: "2024-12-31",
+			want: dates("2024-03-01", "2024-03-02", "2024-03-03"),
+		},
+		{
+			name: "until is inclusive", rule: "FREQ=DAILY;UNTIL=20240303",
+			start: "2024-03-01", from: "2024-01-01", to: "2024-12-31",
+			want: dates("2024-03-01", "2024-03-02", "2024-03-03"),
+		},
+		{
+			name: "count includes occurrences before the range", rule: "FREQ=DAILY;COUNT=3",
+			start: "2024-03-01", from: "2024-03-03", to: "2024-12-31",
+			want: dates("2024-03-03"),
+		},
+		{
+			name: "weekly every other week", rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR;COUNT=5",
+			start: "2024-01-01", from: "2024-01-01", to: "2024-12-31",
+			want: dates("2024-01-01", "2024-01-03", "2024-01-05", "2024-01-15", "2024-01-17"),
+		},
+		{
+			name: "start is the first occurrence", rule: "FREQ=WEEKLY;BYDAY=MO;COUNT=2",
+			start: "2024-01-03", from: "2024-01-01", to: "2024-12-31",
+			want: dates("2024-01-03", "2024-01-08"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.rule, err)
+			}
+
+			got := r.Between(date(tt.start), date(tt.from), date(tt.to))
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Between() = %v, want %v", got, tt.want)
+			}
+
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Fatalf("Between() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBetweenIgnoresTimeOfDay(t *testing.T) {
+	r, err := Parse("FREQ=DAILY;COUNT=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, time.March, 1, 23, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	got := r.Between(start, date("2024-03-01"), date("2024-03-31"))
+	want := dates("2024-03-01", "2024-03-02")
+
+	if len(got) != 2 || !got[0].Equal(want[0]) || !got[1].Equal(want[1]) {
+		t.Errorf("Between() = %v, want %v", got, want)
+	}
+}
+
+func TestCountBeforeAndNext(t *testing.T) {
+	r, err := Parse("FREQ=MONTHLY;BYDAY=-1FR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := date("2024-01-26")
+
+	if got := r.CountBefore(start, date("2024-03-29")); got != 2 {
+		t.Errorf("CountBefore() = %d, want 2", got)
+	}
+
+	next, ok := r.Next(start, date("2024-02-24"))
+	if !ok || !next.Equal(date("2024-03-29")) {
+		t.Errorf("Next() = %v, %v, want 2024-03-29, true", next, ok)
+	}
+
+	if !r.Includes(start, date("2024-04-26")) {
+		t.Error("Includes(2024-04-26) = false, want true")
+	}
+
+	if r.Includes(start, date("2024-04-19")) {
+		t.Error("Includes(2024-04-19) = true, want false")
+	}
+}
+
+func TestNextAfterLastOccurrence(t *testing.T) {
+	r, err := Parse("FREQ=DAILY;UNTIL=20240303")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next, ok := r.Next(date("2024-03-01"), date("2024-03-04")); ok {
+		t.Errorf("Next() = %v, true, want no occurrence", next)
+	}
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage хранилище содержимого файлов. Ключи выбирает вызывающий,
+// они состоят из сегментов, разделённых "/"
+type Storage interface {
+	// Put сохраняет содержимое r под ключом key и возвращает его размер
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open открывает содержимое по ключу, ErrNotFound - если его нет
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete удаляет содержимое по ключу, отсутствие ключа не считается ошибкой
+	Delete(ctx context.Context, key string) error
+}
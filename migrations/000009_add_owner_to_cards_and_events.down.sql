@@ -0,0 +1,10 @@
+
+alter table events drop constraint if exists events_title_check;
+
+alter table events add constraint events_title_check unique (title);
+
+drop index if exists events_owner_id_idx;
+drop index if exists cards_owner_id_idx;
+
+alter table events drop column if exists owner_id;
+alter table cards drop column if exists owner_id;
@@ -0,0 +1,13 @@
+-- rows created before this migration have no owner and are not visible through the API
+
+alter table cards add column if not exists owner_id bigint references users on delete cascade;
+
+alter table events add column if not exists owner_id bigint references users on delete cascade;
+
+create index if not exists cards_owner_id_idx on cards (owner_id);
+
+create index if not exists events_owner_id_idx on events (owner_id);
+
+alter table events drop constraint if exists events_title_check;
+
+alter table events add constraint events_title_check unique (owner_id, title);
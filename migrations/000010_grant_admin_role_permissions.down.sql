@@ -0,0 +1,2 @@
+delete from roles_permissions
+where role_id = (select id from roles where role = 'admin');
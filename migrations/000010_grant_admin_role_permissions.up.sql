@@ -0,0 +1,6 @@
+insert into roles_permissions (role_id, permission_id)
+select roles.id, permissions.id
+from roles
+         cross join permissions
+where roles.role = 'admin'
+on conflict do nothing;
@@ -0,0 +1,2 @@
+delete from permissions
+where permission = 'admin:access';
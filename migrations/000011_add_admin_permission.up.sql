@@ -0,0 +1,11 @@
+insert into permissions (permission, description)
+values ('admin:access', 'manage roles and permissions of other users')
+on conflict do nothing;
+
+insert into roles_permissions (role_id, permission_id)
+select roles.id, permissions.id
+from roles
+         cross join permissions
+where roles.role = 'admin'
+  and permissions.permission = 'admin:access'
+on conflict do nothing;
@@ -0,0 +1,4 @@
+
+drop index if exists events_card_id_idx;
+
+drop index if exists cards_title_idx;
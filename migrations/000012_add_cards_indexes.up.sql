@@ -0,0 +1,4 @@
+
+create index if not exists cards_title_idx on cards using gin(to_tsvector('simple', title));
+
+create index if not exists events_card_id_idx on events (card_id);
@@ -0,0 +1 @@
+alter table cards drop column if exists archived;
@@ -0,0 +1 @@
+alter table cards add column if not exists archived boolean not null default false;
@@ -0,0 +1,10 @@
+
+drop index if exists events_search_vector_idx;
+
+drop trigger if exists events_search_vector_trigger on events;
+
+alter table events drop column if exists search_vector;
+
+drop function if exists events_search_vector_update();
+drop function if exists events_search_document(text, text, text);
+drop function if exists events_blocks_text(text[]);
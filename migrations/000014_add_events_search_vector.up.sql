@@ -0,0 +1,38 @@
+-- текст блоков события, выделен отдельно, чтобы при смене типа text_blocks
+-- достаточно было пересоздать эту функцию
+create or replace function events_blocks_text(blocks text[]) returns text as
+$$
+select array_to_string(blocks, ' ')
+$$ language sql immutable;
+
+-- вектор содержит лексемы английского и русского словарей, поэтому запрос,
+-- построенный по любому из них, использует один и тот же индекс
+create or replace function events_search_document(title text, description text, blocks text) returns tsvector as
+$$
+select setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+       setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+       setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+       setweight(to_tsvector('russian', coalesce(description, '')), 'B') ||
+       setweight(to_tsvector('english', coalesce(blocks, '')), 'C') ||
+       setweight(to_tsvector('russian', coalesce(blocks, '')), 'C')
+$$ language sql immutable;
+
+create or replace function events_search_vector_update() returns trigger as
+$$
+begin
+    new.search_vector := events_search_document(new.title, new.description, events_blocks_text(new.text_blocks));
+    return new;
+end
+$$ language plpgsql;
+
+alter table events add column if not exists search_vector tsvector;
+
+update events set search_vector = events_search_document(title, description, events_blocks_text(text_blocks));
+
+create trigger events_search_vector_trigger
+    before insert or update of title, description, text_blocks
+    on events
+    for each row
+execute function events_search_vector_update();
+
+create index if not exists events_search_vector_idx on events using gin(search_vector);
@@ -0,0 +1,7 @@
+
+drop index if exists events_owner_id_status_idx;
+
+alter table events drop constraint if exists events_status_check;
+
+alter table events drop column if exists completed_at;
+alter table events drop column if exists status;
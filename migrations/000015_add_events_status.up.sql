@@ -0,0 +1,8 @@
+alter table events add column if not exists status text not null default 'todo';
+
+alter table events add column if not exists completed_at timestamp(0) with time zone;
+
+alter table events add constraint events_status_check
+    check ( status in ('todo', 'in_progress', 'done', 'cancelled') );
+
+create index if not exists events_owner_id_status_idx on events (owner_id, status);
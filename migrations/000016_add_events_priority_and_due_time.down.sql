@@ -0,0 +1,5 @@
+
+alter table events drop constraint if exists events_priority_check;
+
+alter table events drop column if exists priority;
+alter table events drop column if exists due_time;
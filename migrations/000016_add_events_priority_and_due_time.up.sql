@@ -0,0 +1,5 @@
+alter table events add column if not exists due_time time with time zone;
+
+alter table events add column if not exists priority smallint not null default 0;
+
+alter table events add constraint events_priority_check check ( priority between 0 and 4 );
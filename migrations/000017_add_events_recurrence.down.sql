@@ -0,0 +1,11 @@
+drop table if exists event_exceptions;
+
+drop index if exists events_title_check;
+
+-- отделённые серии делят название с исходной, уникальность (owner_id, title) без них не восстановить
+update events set title = title || ' #' || id where series_id is not null;
+
+alter table events add constraint events_title_check unique (owner_id, title);
+
+alter table events drop column if exists series_id;
+alter table events drop column if exists rrule;
@@ -0,0 +1,24 @@
+alter table events add column if not exists rrule text;
+
+-- серия, отделённая от исходной при изменении "этого и следующих" повторений
+alter table events add column if not exists series_id bigint references events (id) on delete set null;
+
+-- отделённая серия сохраняет название исходной
+alter table events drop constraint if exists events_title_check;
+
+create unique index if not exists events_title_check on events (owner_id, title) where series_id is null;
+
+-- изменённые или отменённые отдельные повторения
+create table if not exists event_exceptions
+(
+    event_id        bigint  not null references events (id) on delete cascade,
+    occurrence_date date    not null,
+    cancelled       boolean not null default false,
+    title           text,
+    description     text,
+    date            date,
+    due_time        time with time zone,
+    status          text,
+    priority        smallint,
+    primary key (event_id, occurrence_date)
+);
@@ -0,0 +1,22 @@
+create table if not exists reminders
+(
+    id              bigserial primary key,
+    event_id        bigint                   not null references events (id) on delete cascade,
+    owner_id        bigint                   not null references users (id) on delete cascade,
+    -- за сколько минут до события напомнить либо в какое время в день события
+    minutes_before  integer,
+    at_time         time,
+    -- ближайшее время отправки и повторение, к которому оно относится
+    remind_at       timestamp with time zone not null,
+    occurrence_date date,
+    sent_at         timestamp with time zone,
+    attempts        integer                  not null default 0,
+    locked_until    timestamp with time zone,
+    last_error      text,
+    created_at      timestamp with time zone not null default now(),
+    constraint reminders_kind_check check ((minutes_before is null) <> (at_time is null))
+);
+
+create index if not exists reminders_pending_idx on reminders (remind_at) where sent_at is null;
+
+create index if not exists reminders_event_id_idx on reminders (event_id);
@@ -0,0 +1,3 @@
+drop table if exists events_tags;
+
+drop table if exists tags;
@@ -0,0 +1,18 @@
+create table if not exists tags
+(
+    id         bigserial primary key,
+    owner_id   bigint                   not null references users (id) on delete cascade,
+    name       text                     not null,
+    color      text                     not null default '#808080',
+    created_at timestamp with time zone not null default now(),
+    constraint tags_owner_name_key unique (owner_id, name)
+);
+
+create table if not exists events_tags
+(
+    event_id bigint not null references events (id) on delete cascade,
+    tag_id   bigint not null references tags (id) on delete cascade,
+    primary key (event_id, tag_id)
+);
+
+create index if not exists events_tags_tag_id_idx on events_tags (tag_id);
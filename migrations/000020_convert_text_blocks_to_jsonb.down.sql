@@ -0,0 +1,29 @@
+drop trigger if exists events_search_vector_trigger on events;
+
+alter table events drop constraint if exists events_text_blocks_check;
+
+create or replace function events_blocks_to_text(blocks jsonb) returns text[] as
+$$
+select coalesce(array_agg(b.value ->> 'text' order by b.ord), '{}')
+from jsonb_array_elements(blocks) with ordinality as b(value, ord)
+$$ language sql immutable;
+
+alter table events alter column text_blocks type text[] using events_blocks_to_text(text_blocks);
+
+drop function if exists events_blocks_to_text(jsonb);
+
+drop function if exists events_blocks_text(jsonb);
+
+create or replace function events_blocks_text(blocks text[]) returns text as
+$$
+select array_to_string(blocks, ' ')
+$$ language sql immutable;
+
+-- события могли получить больше 10 блоков, поэтому существующие строки не проверяются
+alter table events add constraint events_text_blocks_check check ( array_length(text_blocks, 1) between 0 and 10) not valid;
+
+create trigger events_search_vector_trigger
+    before insert or update of title, description, text_blocks
+    on events
+    for each row
+execute function events_search_vector_update();
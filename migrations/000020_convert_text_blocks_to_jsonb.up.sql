@@ -0,0 +1,35 @@
+-- триггер поиска ссылается на text_blocks, поэтому пересоздаётся после смены типа
+drop trigger if exists events_search_vector_trigger on events;
+
+alter table events drop constraint if exists events_text_blocks_check;
+
+-- каждая строка становится блоком-абзацем со своим идентификатором
+create or replace function events_blocks_from_text(blocks text[]) returns jsonb as
+$$
+select coalesce(jsonb_agg(jsonb_build_object(
+                                  'id', substr(md5(random()::text || b.ord::text), 1, 12),
+                                  'type', 'paragraph',
+                                  'text', b.text) order by b.ord), '[]'::jsonb)
+from unnest(blocks) with ordinality as b(text, ord)
+$$ language sql volatile;
+
+alter table events alter column text_blocks type jsonb using events_blocks_from_text(text_blocks);
+
+drop function if exists events_blocks_from_text(text[]);
+
+drop function if exists events_blocks_text(text[]);
+
+create or replace function events_blocks_text(blocks jsonb) returns text as
+$$
+select string_agg(b ->> 'text', ' ')
+from jsonb_array_elements(blocks) as b
+$$ language sql immutable;
+
+alter table events add constraint events_text_blocks_check
+    check (jsonb_typeof(text_blocks) = 'array' and jsonb_array_length(text_blocks) <= 50);
+
+create trigger events_search_vector_trigger
+    before insert or update of title, description, text_blocks
+    on events
+    for each row
+execute function events_search_vector_update();
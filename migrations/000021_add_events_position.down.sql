@@ -0,0 +1,3 @@
+drop index if exists events_card_id_position_idx;
+
+alter table events drop column if exists position;
@@ -0,0 +1,10 @@
+-- позиция события в карточке; между соседними позициями оставляется промежуток,
+-- чтобы перемещение события меняло только его собственную позицию
+alter table events add column if not exists position double precision not null default 0;
+
+update events
+set position = ordered.rn * 1024
+from (select id, row_number() over (partition by card_id order by date, id) as rn from events) as ordered
+where events.id = ordered.id;
+
+create index if not exists events_card_id_position_idx on events (card_id, position);
@@ -0,0 +1,9 @@
+delete from events where deleted_at is not null;
+
+drop index if exists events_title_check;
+
+create unique index if not exists events_title_check on events (owner_id, title) where series_id is null;
+
+drop index if exists events_deleted_at_idx;
+
+alter table events drop column if exists deleted_at;
@@ -0,0 +1,10 @@
+-- удалённые события остаются в корзине до окончательного удаления
+alter table events add column if not exists deleted_at timestamp(0) with time zone;
+
+create index if not exists events_deleted_at_idx on events (deleted_at) where deleted_at is not null;
+
+-- название должно быть уникальным только среди событий вне корзины
+drop index if exists events_title_check;
+
+create unique index if not exists events_title_check on events (owner_id, title)
+    where series_id is null and deleted_at is null;
@@ -0,0 +1,7 @@
+drop trigger if exists events_revision_update_trigger on events;
+drop trigger if exists events_revision_insert_trigger on events;
+
+drop function if exists events_save_revision();
+drop function if exists events_revision_snapshot(events);
+
+drop table if exists event_revisions;
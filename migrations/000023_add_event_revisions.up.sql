@@ -0,0 +1,69 @@
+-- состояние события в каждой версии; user_id - кто сохранил версию.
+-- Изменять события может только владелец, поэтому триггер берёт owner_id
+create table if not exists event_revisions
+(
+    event_id   bigint                      not null references events (id) on delete cascade,
+    version    integer                     not null,
+    user_id    bigint                      references users (id) on delete set null,
+    created_at timestamp(0) with time zone not null default now(),
+    changed    text[]                      not null default '{}',
+    snapshot   jsonb                       not null,
+    primary key (event_id, version)
+);
+
+-- поля события, которые сохраняются в версии; ключи совпадают с полями API
+create or replace function events_revision_snapshot(e events) returns jsonb as
+$$
+select jsonb_build_object(
+               'title', e.title,
+               'description', e.description,
+               'text_blocks', e.text_blocks,
+               'date', e.date,
+               'due_time', e.due_time,
+               'card_id', e.card_id,
+               'status', e.status,
+               'priority', e.priority,
+               'recurrence', e.rrule,
+               'position', e.position)
+$$ language sql immutable;
+
+create or replace function events_save_revision() returns trigger as
+$$
+declare
+    snapshot jsonb := events_revision_snapshot(new);
+    changed  text[] := '{}';
+begin
+    if tg_op = 'UPDATE' then
+        -- due_time в API входит в поле date
+        select coalesce(array_agg(distinct case s.key when 'due_time' then 'date' else s.key end), '{}')
+        into changed
+        from jsonb_each(snapshot) as s
+        where s.value is distinct from events_revision_snapshot(old) -> s.key;
+    end if;
+
+    insert into event_revisions (event_id, version, user_id, changed, snapshot)
+    values (new.id, new.version, new.owner_id, changed, snapshot)
+    on conflict (event_id, version) do nothing;
+
+    return null;
+end
+$$ language plpgsql;
+
+create trigger events_revision_insert_trigger
+    after insert
+    on events
+    for each row
+execute function events_save_revision();
+
+create trigger events_revision_update_trigger
+    after update of version
+    on events
+    for each row
+    when (new.version <> old.version)
+execute function events_save_revision();
+
+-- текущее состояние существующих событий становится их первой сохранённой версией
+insert into event_revisions (event_id, version, user_id, created_at, snapshot)
+select id, version, owner_id, created_at, events_revision_snapshot(events)
+from events
+on conflict do nothing;
@@ -0,0 +1,15 @@
+-- комментарии к событиям; ответ ссылается на комментарий того же события через parent_id
+create table if not exists comments
+(
+    id         bigserial primary key,
+    event_id   bigint                      not null references events (id) on delete cascade,
+    parent_id  bigint references comments (id) on delete cascade,
+    author_id  bigint                      not null references users (id) on delete cascade,
+    body       text                        not null,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now(),
+    version    integer                     not null default 1
+);
+
+create index if not exists comments_event_id_idx on comments (event_id, created_at);
+create index if not exists comments_parent_id_idx on comments (parent_id);
@@ -0,0 +1,16 @@
+-- файлы событий; содержимое лежит в хранилище под ключом storage_key.
+-- При удалении события event_id становится null, и фоновая задача
+-- удаляет такие файлы из хранилища
+create table if not exists attachments
+(
+    id           bigserial primary key,
+    event_id     bigint references events (id) on delete set null,
+    filename     text                        not null,
+    content_type text                        not null,
+    size         bigint                      not null,
+    storage_key  text                        not null unique,
+    created_at   timestamp(0) with time zone not null default now()
+);
+
+create index if not exists attachments_event_id_idx on attachments (event_id);
+create index if not exists attachments_orphaned_idx on attachments (id) where event_id is null;
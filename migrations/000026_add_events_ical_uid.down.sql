@@ -0,0 +1,3 @@
+drop index if exists events_ical_uid_key;
+
+alter table events drop column if exists ical_uid;
@@ -0,0 +1,5 @@
+-- UID события из импортированного календаря; повторный импорт находит событие по нему
+alter table events add column if not exists ical_uid text;
+
+create unique index if not exists events_ical_uid_key on events (owner_id, ical_uid)
+    where ical_uid is not null and deleted_at is null;
@@ -0,0 +1,8 @@
+delete from tokens where scope = 'feed';
+
+drop index if exists tokens_user_id_scope_idx;
+drop index if exists tokens_id_key;
+
+alter table tokens drop column if exists created_at;
+alter table tokens drop column if exists card_id;
+alter table tokens drop column if exists id;
@@ -0,0 +1,7 @@
+-- Токены календарных подписок показываются пользователю списком и отзываются по id
+alter table tokens add column if not exists id bigserial;
+alter table tokens add column if not exists card_id bigint references cards on delete cascade;
+alter table tokens add column if not exists created_at timestamp(0) with time zone not null default now();
+
+create unique index if not exists tokens_id_key on tokens (id);
+create index if not exists tokens_user_id_scope_idx on tokens (user_id, scope);